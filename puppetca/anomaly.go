@@ -0,0 +1,156 @@
+package puppetca
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AnomalyKind classifies an inconsistency found in the CA
+type AnomalyKind string
+
+const (
+	// AnomalyUnknownState is a status in a state other than requested,
+	// signed or revoked
+	AnomalyUnknownState AnomalyKind = "unknown_state"
+	// AnomalyDuplicateCertname is a certname listed more than once with
+	// differing fingerprints
+	AnomalyDuplicateCertname AnomalyKind = "duplicate_certname"
+	// AnomalyMissingCert is a signed status whose certificate the CA does
+	// not serve
+	AnomalyMissingCert AnomalyKind = "missing_cert"
+	// AnomalyFingerprintMismatch is a signed status whose fingerprint does
+	// not match the certificate served for it
+	AnomalyFingerprintMismatch AnomalyKind = "fingerprint_mismatch"
+	// AnomalyUnparsableCert is a signed status whose certificate is served
+	// but cannot be parsed
+	AnomalyUnparsableCert AnomalyKind = "unparsable_cert"
+	// AnomalyInvalidFingerprint is a signed status whose SHA256 fingerprint
+	// is not well formed
+	AnomalyInvalidFingerprint AnomalyKind = "invalid_fingerprint"
+)
+
+// Anomaly describes an inconsistency affecting a certname
+type Anomaly struct {
+	Certname string
+	Kind     AnomalyKind
+	Detail   string
+}
+
+// FindAnomalies scans all certificate statuses and reports inconsistencies
+// between them and the certificates served by the CA. Anomalies are sorted
+// by certname. Failing to fetch a certificate for a reason other than a 404
+// is returned as an error; a certificate or fingerprint that is served but
+// malformed is reported as an anomaly and the scan goes on.
+func (c *Client) FindAnomalies() ([]Anomaly, error) {
+	statuses, err := c.ListCertStatuses()
+	if err != nil {
+		return nil, err
+	}
+	return c.findAnomalies(statuses)
+}
+
+func (c *Client) findAnomalies(statuses []CertStatus) ([]Anomaly, error) {
+	var (
+		mu        sync.Mutex
+		anomalies []Anomaly
+		firstErr  error
+	)
+	add := func(a Anomaly) {
+		mu.Lock()
+		anomalies = append(anomalies, a)
+		mu.Unlock()
+	}
+
+	byName := make(map[string]CertStatus, len(statuses))
+	var signed []string
+	for _, s := range statuses {
+		if prev, ok := byName[s.Name]; ok {
+			if statusFingerprint(prev) != statusFingerprint(s) {
+				add(Anomaly{
+					Certname: s.Name,
+					Kind:     AnomalyDuplicateCertname,
+					Detail:   fmt.Sprintf("listed as %s (%s) and %s (%s)", prev.State, prev.Fingerprint, s.State, s.Fingerprint),
+				})
+			}
+			continue
+		}
+		byName[s.Name] = s
+		switch s.State {
+		case "requested", "revoked":
+		case "signed":
+			signed = append(signed, s.Name)
+		default:
+			add(Anomaly{Certname: s.Name, Kind: AnomalyUnknownState, Detail: fmt.Sprintf("state %q", s.State)})
+		}
+	}
+
+	c.forEach(signed, func(name string) {
+		pem, err := c.GetCertByName(name)
+		if IsNotFound(err) {
+			add(Anomaly{Certname: name, Kind: AnomalyMissingCert, Detail: err.Error()})
+			return
+		}
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+		cert, err := parseCertPEM(pem)
+		if err != nil {
+			add(Anomaly{Certname: name, Kind: AnomalyUnparsableCert, Detail: err.Error()})
+			return
+		}
+		want := statusFingerprint(byName[name])
+		if want != "" && !isSHA256Fingerprint(want) {
+			add(Anomaly{Certname: name, Kind: AnomalyInvalidFingerprint, Detail: fmt.Sprintf("status has %q", want)})
+			return
+		}
+		if got := sha256Fingerprint(cert); want != "" && got != want {
+			add(Anomaly{
+				Certname: name,
+				Kind:     AnomalyFingerprintMismatch,
+				Detail:   fmt.Sprintf("status has %s, certificate has %s", want, got),
+			})
+		}
+	})
+
+	if firstErr != nil {
+		return nil, errors.Wrap(firstErr, "failed to check signed certificates")
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Certname < anomalies[j].Certname
+	})
+	return anomalies, nil
+}
+
+// statusFingerprint returns the normalized SHA256 fingerprint of a status
+func statusFingerprint(s CertStatus) string {
+	fp := s.Fingerprints["SHA256"]
+	if fp == "" {
+		fp = s.Fingerprint
+	}
+	return normalizeFingerprint(fp)
+}
+
+func sha256Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("%X", sum[:])
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToUpper(strings.Replace(fp, ":", "", -1))
+}
+
+// isSHA256Fingerprint reports whether fp is a normalized SHA256 fingerprint
+func isSHA256Fingerprint(fp string) bool {
+	return len(fp) == 2*sha256.Size && strings.Trim(fp, "0123456789ABCDEF") == ""
+}
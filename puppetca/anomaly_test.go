@@ -0,0 +1,78 @@
+package puppetca
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFindAnomalies(t *testing.T) {
+	certPEM, _ := testClientCert(t)
+	cert, err := parseCertPEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := sha256Fingerprint(cert)
+	statuses := []CertStatus{
+		{Name: "good", State: "signed", Fingerprints: map[string]string{"SHA256": fp}},
+		{Name: "missing", State: "signed", Fingerprints: map[string]string{"SHA256": fp}},
+		{Name: "garbled", State: "signed", Fingerprints: map[string]string{"SHA256": fp}},
+		{Name: "badfp", State: "signed", Fingerprints: map[string]string{"SHA256": "not-a-fingerprint"}},
+		{Name: "mismatch", State: "signed", Fingerprints: map[string]string{"SHA256": strings.Repeat("AB", sha256.Size)}},
+		{Name: "odd", State: "limbo"},
+	}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/certificate_statuses/any"):
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(statuses)
+		case strings.HasSuffix(r.URL.Path, "/certificate/missing"):
+			http.NotFound(w, r)
+		case strings.HasSuffix(r.URL.Path, "/certificate/garbled"):
+			fmt.Fprint(w, "-----BEGIN CERTIFICATE-----\nZ2FyYmxlZA==\n-----END CERTIFICATE-----\n")
+		default:
+			fmt.Fprint(w, certPEM)
+		}
+	})
+
+	anomalies, err := c.FindAnomalies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]AnomalyKind)
+	for _, a := range anomalies {
+		got[a.Certname] = a.Kind
+	}
+	want := map[string]AnomalyKind{
+		"missing":  AnomalyMissingCert,
+		"garbled":  AnomalyUnparsableCert,
+		"badfp":    AnomalyInvalidFingerprint,
+		"mismatch": AnomalyFingerprintMismatch,
+		"odd":      AnomalyUnknownState,
+	}
+	if len(got) != len(want) {
+		t.Errorf("anomalies = %v, want %v", got, want)
+	}
+	for name, kind := range want {
+		if got[name] != kind {
+			t.Errorf("%s: kind = %q, want %q", name, got[name], kind)
+		}
+	}
+}
+
+func TestFindAnomaliesReturnsFetchErrors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/certificate_statuses/any") {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"name":"a","state":"signed"}]`)
+			return
+		}
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	if _, err := c.FindAnomalies(); err == nil {
+		t.Fatal("FindAnomalies succeeded despite a 500 fetching a certificate")
+	}
+}
@@ -0,0 +1,90 @@
+package puppetca
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultPort is the port Puppet Server listens on by default
+const defaultPort = "8140"
+
+// WithRequirePort makes the constructor reject base URLs without an explicit
+// port instead of defaulting to 8140
+func WithRequirePort() Option {
+	return func(c *Client) {
+		c.requirePort = true
+	}
+}
+
+// WithLogger sets a logger for informational messages, such as the port
+// chosen when the base URL has none. No messages are logged by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// normalizeBaseURL checks that baseURL uses https and has a host, trims
+// trailing slashes and adds port 8140 if it has no port, unless a port is
+// required
+func normalizeBaseURL(baseURL string, requirePort bool) (normalized string, defaulted bool, err error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid base URL %q: %v", baseURL, err)
+	}
+	if u.Scheme != "https" {
+		return "", false, fmt.Errorf("base URL %q must use https; Puppet CA does not serve plaintext", baseURL)
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("base URL %q has no host", baseURL)
+	}
+	if u.Port() == "" {
+		if requirePort {
+			return "", false, fmt.Errorf("base URL %q has no port; Puppet Server listens on 8140 by default, but a CA-only service may use another port", baseURL)
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+		defaulted = true
+	}
+	return u.String(), defaulted, nil
+}
+
+// setBaseURL validates, normalizes and applies baseURL
+func (c *Client) setBaseURL(baseURL string) error {
+	normalized, defaulted, err := normalizeBaseURL(baseURL, c.requirePort)
+	if err != nil {
+		return err
+	}
+	if defaulted {
+		c.logf("puppetca: no port in base URL %s, using default port %s", baseURL, defaultPort)
+	}
+	c.baseURL.Store(normalized)
+	return nil
+}
+
+// SetBaseURL validates baseURL like NewClient does and atomically switches
+// the client to it. It is safe to call concurrently with requests: requests
+// already started complete against the previous URL. On error the current
+// base URL is kept.
+func (c *Client) SetBaseURL(baseURL string) error {
+	if c.baseURL == nil {
+		return fmt.Errorf("client was not created by NewClient")
+	}
+	return c.setBaseURL(baseURL)
+}
+
+func (c *Client) getBaseURL() string {
+	if c.baseURL == nil {
+		return ""
+	}
+	s, _ := c.baseURL.Load().(string)
+	return s
+}
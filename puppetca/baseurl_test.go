@@ -0,0 +1,80 @@
+package puppetca
+
+import (
+	"bytes"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		baseURL       string
+		requirePort   bool
+		want          string
+		wantDefaulted bool
+		wantErr       string
+	}{
+		{baseURL: "https://ca.example.com:8141", want: "https://ca.example.com:8141"},
+		{baseURL: "https://ca.example.com", want: "https://ca.example.com:8140", wantDefaulted: true},
+		{baseURL: "https://ca.example.com///", want: "https://ca.example.com:8140", wantDefaulted: true},
+		{baseURL: "HTTPS://ca.example.com:443/puppet/", want: "https://ca.example.com:443/puppet"},
+		{baseURL: "https://[2001:db8::1]", want: "https://[2001:db8::1]:8140", wantDefaulted: true},
+		{baseURL: "https://ca.example.com:8140", requirePort: true, want: "https://ca.example.com:8140"},
+		{baseURL: "https://ca.example.com", requirePort: true, wantErr: "has no port"},
+		{baseURL: "http://ca.example.com:8140", wantErr: "must use https"},
+		{baseURL: "ca.example.com:8140", wantErr: "must use https"},
+		{baseURL: "https://:8140", wantErr: "has no host"},
+		{baseURL: "https://ca.example.com:8140/%zz", wantErr: "invalid base URL"},
+	}
+	for _, tt := range tests {
+		got, defaulted, err := normalizeBaseURL(tt.baseURL, tt.requirePort)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("normalizeBaseURL(%q, %v) error = %v, want %q", tt.baseURL, tt.requirePort, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalizeBaseURL(%q, %v): %v", tt.baseURL, tt.requirePort, err)
+			continue
+		}
+		if got != tt.want || defaulted != tt.wantDefaulted {
+			t.Errorf("normalizeBaseURL(%q, %v) = %q, %v, want %q, %v", tt.baseURL, tt.requirePort, got, defaulted, tt.want, tt.wantDefaulted)
+		}
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	var logged bytes.Buffer
+	tr := &http.Transport{TLSClientConfig: &tls.Config{}}
+	c, err := NewClientSharingTransport("https://ca.example.com", tr, WithLogger(log.New(&logged, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://ca.example.com:8140"; c.getBaseURL() != want {
+		t.Errorf("base URL = %s, want %s", c.getBaseURL(), want)
+	}
+	if !strings.Contains(logged.String(), "using default port 8140") {
+		t.Errorf("log = %q, want the defaulted port reported", logged.String())
+	}
+
+	if err := c.SetBaseURL("https://ca2.example.com:9140/"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://ca2.example.com:9140"; c.getBaseURL() != want {
+		t.Errorf("base URL = %s, want %s", c.getBaseURL(), want)
+	}
+	if err := c.SetBaseURL("http://ca3.example.com:8140"); err == nil {
+		t.Error("SetBaseURL accepted plain http")
+	}
+	if want := "https://ca2.example.com:9140"; c.getBaseURL() != want {
+		t.Errorf("base URL after a rejected switch = %s, want %s", c.getBaseURL(), want)
+	}
+
+	if _, err := NewClientSharingTransport("https://ca.example.com", tr, WithRequirePort()); err == nil {
+		t.Error("WithRequirePort accepted a base URL without a port")
+	}
+}
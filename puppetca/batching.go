@@ -0,0 +1,68 @@
+package puppetca
+
+import (
+	"sync"
+	"time"
+)
+
+// WithStatusBatching makes GetCertStatus serve statuses from a snapshot of
+// all statuses, fetched with a single list request and refreshed once older
+// than ttl. Statuses missing from the snapshot are fetched individually, as
+// are all statuses while listing fails; a failed list is only retried once
+// ttl has passed. The snapshot is discarded after every mutating request the
+// client makes, so it never serves a state the client changed.
+func WithStatusBatching(ttl time.Duration) Option {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.statusBatch = &statusSnapshot{ttl: ttl}
+		} else {
+			c.statusBatch = nil
+		}
+	}
+}
+
+// statusSnapshot holds the statuses of all certificates. Its mutex is held
+// while refreshing, so concurrent readers share a single list request.
+type statusSnapshot struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	statuses map[string]CertStatus
+	err      error
+	fetched  time.Time
+}
+
+// get returns the status of name from the snapshot, refreshing it first if
+// it is older than the TTL. A failed refresh is remembered for the TTL too.
+func (s *statusSnapshot) get(c *Client, name string) (CertStatus, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := c.clock()
+	if (s.statuses == nil && s.err == nil) || now.Sub(s.fetched) > s.ttl {
+		list, err := c.ListCertStatuses()
+		s.fetched = now
+		if err != nil {
+			s.statuses, s.err = nil, err
+			return CertStatus{}, false, err
+		}
+		s.err = nil
+		s.statuses = make(map[string]CertStatus, len(list))
+		for _, status := range list {
+			s.statuses[status.Name] = status
+		}
+	}
+	if s.err != nil {
+		return CertStatus{}, false, s.err
+	}
+	status, ok := s.statuses[name]
+	return status, ok, nil
+}
+
+// invalidate discards the snapshot, so that the next read refreshes it
+func (s *statusSnapshot) invalidate() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses, s.err = nil, nil
+}
@@ -0,0 +1,79 @@
+package puppetca
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatusBatchingServesFromSnapshot(t *testing.T) {
+	var lists, singles int32
+	state := "requested"
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method != "GET":
+			state = "signed"
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/certificate_statuses/any"):
+			atomic.AddInt32(&lists, 1)
+			json.NewEncoder(w).Encode([]CertStatus{{Name: "a", State: state}, {Name: "b", State: state}})
+		default:
+			atomic.AddInt32(&singles, 1)
+			name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			json.NewEncoder(w).Encode(CertStatus{Name: name, State: state})
+		}
+	}, WithStatusBatching(time.Hour))
+
+	for _, name := range []string{"a", "b", "a", "missing"} {
+		if _, err := c.GetCertStatus(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lists != 1 || singles != 1 {
+		t.Errorf("%d lists and %d single reads, want 1 and 1", lists, singles)
+	}
+
+	// A raw Put changes state on the server and must discard the snapshot
+	if _, err := c.Put("certificate_status/a", `{"desired_state":"signed"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.GetCertStatus("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != "signed" || lists != 2 {
+		t.Errorf("after Put: state %s with %d lists, want signed with 2", status.State, lists)
+	}
+}
+
+func TestStatusBatchingRemembersListFailure(t *testing.T) {
+	var lists, singles int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/certificate_statuses/any") {
+			atomic.AddInt32(&lists, 1)
+			http.Error(w, "list denied", http.StatusForbidden)
+			return
+		}
+		atomic.AddInt32(&singles, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name":"a","state":"signed"}`)
+	}, WithStatusBatching(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		status, err := c.GetCertStatus("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != "signed" {
+			t.Errorf("state = %s, want signed", status.State)
+		}
+	}
+	if lists != 1 || singles != 3 {
+		t.Errorf("%d lists and %d single reads, want 1 and 3", lists, singles)
+	}
+}
@@ -0,0 +1,80 @@
+package puppetca
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBodyReadTimeout is returned when the server stops sending the response
+// body for longer than the configured body read timeout
+var ErrBodyReadTimeout = errors.New("response body read timed out")
+
+// WithBodyReadTimeout aborts a request when no response body data arrives
+// for d, independently of any overall request timeout. The window restarts
+// on every successful read.
+func WithBodyReadTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.bodyReadTimeout = d
+	}
+}
+
+// stallReader cancels a request when reads stall for longer than timeout
+type stallReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+	stalled int32
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil && err != io.EOF && atomic.LoadInt32(&s.stalled) == 1 {
+		err = errors.Wrapf(ErrBodyReadTimeout, "no data received for %s", s.timeout)
+	}
+	return n, err
+}
+
+// withBodyDeadline returns a request bound to a cancellable context when a
+// body read timeout is configured, with the function cancelling it
+func (c *Client) withBodyDeadline(req *http.Request) (*http.Request, context.CancelFunc) {
+	if c.bodyReadTimeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	return req.WithContext(ctx), cancel
+}
+
+// watchBody returns a reader over body that cancels the request through
+// cancel if reads stall for longer than the body read timeout, and the
+// function to call once reading is done
+func (c *Client) watchBody(body io.Reader, cancel context.CancelFunc) (io.Reader, func()) {
+	if c.bodyReadTimeout <= 0 {
+		return body, func() {}
+	}
+	sr := &stallReader{r: body, timeout: c.bodyReadTimeout}
+	sr.timer = time.AfterFunc(c.bodyReadTimeout, func() {
+		atomic.StoreInt32(&sr.stalled, 1)
+		cancel()
+	})
+	return sr, func() { sr.timer.Stop() }
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
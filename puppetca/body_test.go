@@ -0,0 +1,68 @@
+package puppetca
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBodyReadTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		stalled bool
+	}{
+		{
+			name: "stalled body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "-----BEGIN")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			},
+			stalled: true,
+		},
+		{
+			name: "slow but steady body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 6; i++ {
+					fmt.Fprint(w, "ca")
+					w.(http.Flusher).Flush()
+					time.Sleep(timeout / 3)
+				}
+			},
+			want: strings.Repeat("ca", 6),
+		},
+		{
+			name: "slow headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(2 * timeout)
+				fmt.Fprint(w, "ca")
+			},
+			want: "ca",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.handler, WithBodyReadTimeout(timeout))
+			got, err := c.GetCACert()
+			if tt.stalled {
+				if !errors.Is(err, ErrBodyReadTimeout) {
+					t.Fatalf("GetCACert error = %v, want ErrBodyReadTimeout", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCACert: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetCACert = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package puppetca
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RequestBuilder builds a request to an arbitrary CA API endpoint. It is
+// sent through the same HTTP client, and thus the same TLS configuration
+// and options, as the other Client methods.
+type RequestBuilder struct {
+	client  *Client
+	method  string
+	path    string
+	query   url.Values
+	headers map[string]string
+	body    *string
+}
+
+// Response is the response to a request sent by a RequestBuilder
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	body       []byte
+}
+
+// Request returns a builder for a GET request to the API root
+func (c *Client) Request() *RequestBuilder {
+	return &RequestBuilder{
+		client:  c,
+		method:  "GET",
+		query:   url.Values{},
+		headers: map[string]string{},
+	}
+}
+
+// Method sets the HTTP method
+func (b *RequestBuilder) Method(method string) *RequestBuilder {
+	b.method = strings.ToUpper(method)
+	return b
+}
+
+// Path sets the endpoint path, relative to the puppet-ca/v1 prefix
+func (b *RequestBuilder) Path(path string) *RequestBuilder {
+	b.path = path
+	return b
+}
+
+// Query adds a query parameter
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Header sets a request header
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.headers[key] = value
+	return b
+}
+
+// Accept sets the Accept header
+func (b *RequestBuilder) Accept(contentType string) *RequestBuilder {
+	return b.Header("Accept", contentType)
+}
+
+// Body sets the request body and its content type
+func (b *RequestBuilder) Body(contentType, body string) *RequestBuilder {
+	b.body = &body
+	return b.Header("Content-Type", contentType)
+}
+
+// Send sends the request
+func (b *RequestBuilder) Send(ctx context.Context) (*Response, error) {
+	var body io.Reader
+	if b.body != nil {
+		body = strings.NewReader(*b.body)
+	}
+	req, err := b.client.newHTTPRequest(ctx, b.method, b.path, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.query) > 0 {
+		req.URL.RawQuery = b.query.Encode()
+	}
+	resp, err := b.client.do(req, b.headers)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: resp.statusCode, Header: resp.header, body: resp.body}, nil
+}
+
+// Bytes returns the response body
+func (r *Response) Bytes() []byte {
+	return r.body
+}
+
+// String returns the response body as a string
+func (r *Response) String() string {
+	return string(r.body)
+}
+
+// JSON decodes the response body into v
+func (r *Response) JSON(v interface{}) error {
+	if err := json.Unmarshal(r.body, v); err != nil {
+		return errors.Wrap(err, "failed to decode JSON response")
+	}
+	return nil
+}
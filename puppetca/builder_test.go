@@ -0,0 +1,95 @@
+package puppetca
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Puppet-Version", "7.9.0")
+		fmt.Fprint(w, `{"name":"agent.example.com","state":"signed"}`)
+	})
+
+	resp, err := c.Request().
+		Method("put").
+		Path("certificate_status/agent.example.com").
+		Query("environment", "production").
+		Query("environment", "staging").
+		Accept("application/json").
+		Header("X-Custom", "yes").
+		Body("text/pson", `{"desired_state":"signed"}`).
+		Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Method != "PUT" {
+		t.Errorf("method = %s, want PUT", got.Method)
+	}
+	if want := "/puppet-ca/v1/certificate_status/agent.example.com"; got.URL.Path != want {
+		t.Errorf("path = %s, want %s", got.URL.Path, want)
+	}
+	if q := got.URL.Query()["environment"]; len(q) != 2 || q[0] != "production" || q[1] != "staging" {
+		t.Errorf("environment query = %v, want both values in order", q)
+	}
+	for header, want := range map[string]string{"Accept": "application/json", "X-Custom": "yes", "Content-Type": "text/pson"} {
+		if v := got.Header.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+	if gotBody != `{"desired_state":"signed"}` {
+		t.Errorf("body = %q", gotBody)
+	}
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Puppet-Version") != "7.9.0" {
+		t.Errorf("response = %d %v", resp.StatusCode, resp.Header)
+	}
+	var status CertStatus
+	if err := resp.JSON(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Name != "agent.example.com" || status.State != "signed" {
+		t.Errorf("decoded %+v", status)
+	}
+	if resp.String() != string(resp.Bytes()) || resp.String() == "" {
+		t.Errorf("String = %q, Bytes = %q", resp.String(), resp.Bytes())
+	}
+}
+
+func TestRequestBuilderDefaultsAndErrors(t *testing.T) {
+	var got *http.Request
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		if r.URL.Path == "/puppet-ca/v1/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "not json")
+	})
+
+	resp, err := c.Request().Path("certificate/ca").Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "GET" || got.URL.RawQuery != "" || got.ContentLength != 0 {
+		t.Errorf("default request = %s %s, %d body bytes", got.Method, got.URL, got.ContentLength)
+	}
+	var v interface{}
+	if err := resp.JSON(&v); err == nil {
+		t.Error("JSON decoded a non-JSON body")
+	}
+
+	if _, err := c.Request().Path("missing").Send(context.Background()); !IsNotFound(err) {
+		t.Errorf("Send error = %v, want a 404", err)
+	}
+}
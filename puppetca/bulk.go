@@ -0,0 +1,119 @@
+package puppetca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BulkRevoke revokes the certificates of the given nodes and returns the
+// result of each revocation, keyed by node name. The returned error is
+// non-nil if any revocation failed.
+//
+// The Puppet CA API offers no way to defer CRL regeneration, so servers
+// regenerate the CRL on every revocation. Revocations are therefore issued
+// sequentially, which avoids concurrent CRL rewrites contending on the
+// server; the CRL is consistent once BulkRevoke returns.
+func (c *Client) BulkRevoke(nodenames []string) (map[string]error, error) {
+	results := make(map[string]error, len(nodenames))
+	failed := 0
+	for _, nodename := range nodenames {
+		err := c.RevokeCertByName(nodename)
+		if err != nil {
+			failed++
+		}
+		results[nodename] = err
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("failed to revoke %d of %d certificates", failed, len(nodenames))
+	}
+	return results, nil
+}
+
+// CleanCerts revokes and deletes the certificates of the given nodes. If
+// cached Capabilities show the server has the clean endpoint, all nodes are
+// cleaned with a single request; otherwise each is revoked and deleted in
+// turn, bound to ctx.
+func (c *Client) CleanCerts(ctx context.Context, nodenames []string) error {
+	for _, nodename := range nodenames {
+		if err := c.checkCertname(nodename); err != nil {
+			return err
+		}
+	}
+	if caps, ok := c.CachedCapabilities(); ok && caps.BulkClean {
+		body, err := json.Marshal(struct {
+			Certnames []string `json:"certnames"`
+		}{nodenames})
+		if err != nil {
+			return errors.Wrap(err, "failed to encode clean request")
+		}
+		headers := map[string]string{"Content-Type": "application/json"}
+		if _, err := c.send(ctx, "PUT", "clean", strings.NewReader(string(body)), headers); err != nil {
+			return errors.Wrapf(err, "failed to clean %d certificates", len(nodenames))
+		}
+		return nil
+	}
+
+	var failed []string
+	for _, nodename := range nodenames {
+		err := c.revokeCertByName(ctx, nodename)
+		if err == nil {
+			err = c.deleteCertByName(ctx, nodename)
+		}
+		if err != nil {
+			failed = append(failed, nodename)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to clean %d of %d certificates: %s", len(failed), len(nodenames), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// NodeResult is the outcome of an operation on one node
+type NodeResult struct {
+	Certname  string
+	Operation string
+	Err       error
+}
+
+// SignAllPendingStream signs every pending CSR, bounded by the client
+// concurrency, and streams one result per node as each sign completes. If
+// listing pending requests fails, a single result with an empty Certname
+// and the "list" operation is sent. Every request is bound to ctx. The
+// channel is closed once all results are sent or ctx is done; no goroutine
+// outlives that.
+func (c *Client) SignAllPendingStream(ctx context.Context) <-chan NodeResult {
+	results := make(chan NodeResult)
+	go func() {
+		defer close(results)
+		send := func(r NodeResult) {
+			select {
+			case results <- r:
+			case <-ctx.Done():
+			}
+		}
+
+		statuses, err := c.listCertStatuses(ctx)
+		if err != nil {
+			send(NodeResult{Operation: "list", Err: err})
+			return
+		}
+		var pending []string
+		for _, s := range statuses {
+			if s.State == "requested" {
+				pending = append(pending, s.Name)
+			}
+		}
+		c.forEach(pending, func(name string) {
+			if ctx.Err() != nil {
+				return
+			}
+			send(NodeResult{Certname: name, Operation: "sign", Err: c.signRequestWithOptions(ctx, name, SignOptions{})})
+		})
+	}()
+	return results
+}
@@ -0,0 +1,109 @@
+package puppetca
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// drain collects the results of a stream, failing if it is not closed
+// within timeout
+func drain(t *testing.T, results <-chan NodeResult, timeout time.Duration) []NodeResult {
+	t.Helper()
+	var got []NodeResult
+	deadline := time.After(timeout)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				return got
+			}
+			got = append(got, r)
+		case <-deadline:
+			t.Fatalf("stream not closed after %v", timeout)
+		}
+	}
+}
+
+func TestSignAllPendingStream(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/certificate_statuses/any"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"name":"good.example.com","state":"requested"},{"name":"bad.example.com","state":"requested"},{"name":"done.example.com","state":"signed"}]`)
+		case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/certificate_status/bad.example.com"):
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case r.Method == "PUT":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	results := make(map[string]NodeResult)
+	for _, r := range drain(t, c.SignAllPendingStream(context.Background()), 5*time.Second) {
+		results[r.Certname] = r
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %v, want one per pending CSR", results)
+	}
+	if r := results["good.example.com"]; r.Operation != "sign" || r.Err != nil {
+		t.Errorf("good.example.com: %+v, want a successful sign", r)
+	}
+	if r := results["bad.example.com"]; r.Operation != "sign" || r.Err == nil {
+		t.Errorf("bad.example.com: %+v, want a failed sign", r)
+	}
+}
+
+func TestSignAllPendingStreamListFailure(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	got := drain(t, c.SignAllPendingStream(context.Background()), 5*time.Second)
+	if len(got) != 1 || got[0].Certname != "" || got[0].Operation != "list" || got[0].Err == nil {
+		t.Errorf("results = %+v, want a single list failure", got)
+	}
+}
+
+func TestSignAllPendingStreamCancel(t *testing.T) {
+	t.Run("while listing", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		results := c.SignAllPendingStream(ctx)
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		for _, r := range drain(t, results, 5*time.Second) {
+			if r.Err == nil {
+				t.Errorf("result %+v after cancellation, want an error", r)
+			}
+		}
+	})
+
+	t.Run("with an idle consumer", func(t *testing.T) {
+		var names []string
+		for i := 0; i < 10; i++ {
+			names = append(names, fmt.Sprintf(`{"name":"node%d.example.com","state":"requested"}`, i))
+		}
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, "[%s]", strings.Join(names, ","))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		results := c.SignAllPendingStream(ctx)
+		// Read one result, then stop reading; pending sends must give up
+		if _, ok := <-results; !ok {
+			t.Fatal("stream closed before any result")
+		}
+		cancel()
+		drain(t, results, 5*time.Second)
+	})
+}
@@ -0,0 +1,224 @@
+package puppetca
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Bootstrap holds the trust material a new agent needs
+type Bootstrap struct {
+	CACerts       []*x509.Certificate
+	CRL           *x509.RevocationList
+	ServerVersion string
+}
+
+// GetCACert returns the CA certificate, which may be a PEM bundle when the
+// CA is an intermediate
+func (c *Client) GetCACert() (string, error) {
+	pem, _, err := c.getCACert(context.Background())
+	return pem, err
+}
+
+func (c *Client) getCACert(ctx context.Context) (pem, version string, err error) {
+	req, err := c.newHTTPRequest(ctx, "GET", "certificate/ca", nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := c.do(req, nil)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to retrieve CA certificate")
+	}
+	return string(resp.body), resp.header.Get("X-Puppet-Version"), nil
+}
+
+// GetCACertBundle returns the parsed certificates of the CA bundle, in the
+// order served by the CA
+func (c *Client) GetCACertBundle() ([]*x509.Certificate, error) {
+	pem, err := c.GetCACert()
+	if err != nil {
+		return nil, err
+	}
+	return parseCertBundle(pem)
+}
+
+// GetCRL returns the certificate revocation list, which may be a PEM
+// bundle when the CA is an intermediate
+func (c *Client) GetCRL() (string, error) {
+	return c.getCRL(context.Background())
+}
+
+func (c *Client) getCRL(ctx context.Context) (string, error) {
+	crl, err := c.send(ctx, "GET", "certificate_revocation_list/ca", nil, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to retrieve CRL")
+	}
+	return crl, nil
+}
+
+// GetParsedCRL returns the first CRL served by the CA, which is the one
+// issued by the signing CA
+func (c *Client) GetParsedCRL() (*x509.RevocationList, error) {
+	pem, err := c.GetCRL()
+	if err != nil {
+		return nil, err
+	}
+	return parseCRLPEM(pem)
+}
+
+// BootstrapInfo fetches the CA certificate and CRL concurrently and checks
+// that the CRL is signed by the CA
+func (c *Client) BootstrapInfo() (*Bootstrap, error) {
+	var (
+		wg             sync.WaitGroup
+		caPEM, version string
+		crlPEM         string
+		caErr, crlErr  error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		caPEM, version, caErr = c.getCACert(context.Background())
+	}()
+	go func() {
+		defer wg.Done()
+		crlPEM, crlErr = c.GetCRL()
+	}()
+	wg.Wait()
+	if caErr != nil {
+		return nil, errors.Wrap(caErr, "bootstrap: CA certificate")
+	}
+	if crlErr != nil {
+		return nil, errors.Wrap(crlErr, "bootstrap: CRL")
+	}
+
+	caCerts, err := parseCertBundle(caPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "bootstrap: CA certificate")
+	}
+	crl, err := parseCRLPEM(crlPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "bootstrap: CRL")
+	}
+	if err := checkCRLSignature(crl, caCerts); err != nil {
+		return nil, errors.Wrap(err, "bootstrap: CRL")
+	}
+
+	return &Bootstrap{CACerts: caCerts, CRL: crl, ServerVersion: version}, nil
+}
+
+func parseCertBundle(pemStr string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(pemStr)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse certificate")
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+func parseCRLPEM(pemStr string) (*x509.RevocationList, error) {
+	rest := []byte(pemStr)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM CRL found")
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse CRL")
+		}
+		return crl, nil
+	}
+}
+
+func checkCRLSignature(crl *x509.RevocationList, caCerts []*x509.Certificate) error {
+	for _, ca := range caCerts {
+		if crl.CheckSignatureFrom(ca) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("CRL issued by %s is not signed by the CA", crl.Issuer)
+}
+
+// signingCA returns the CA certificate that signs node certificates, the
+// first one of the bundle
+func (c *Client) signingCA() (*x509.Certificate, error) {
+	bundle, err := c.GetCACertBundle()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get CA certificate")
+	}
+	return bundle[0], nil
+}
+
+// IsRootCA returns true if the signing CA is a root, i.e. its issuer is its
+// subject and it verifies its own signature, and false if it is an
+// intermediate
+func (c *Client) IsRootCA() (bool, error) {
+	ca, err := c.signingCA()
+	if err != nil {
+		return false, err
+	}
+	return isSelfSigned(ca), nil
+}
+
+// CAIssuer returns the issuer DN of the signing CA, which is its own
+// subject for a root CA
+func (c *Client) CAIssuer() (pkix.Name, error) {
+	ca, err := c.signingCA()
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	return ca.Issuer, nil
+}
+
+// TrustMaterialReader returns a reader over the CA certificates followed by
+// the CRLs, as PEM blocks in the order served by the CA: the "CERTIFICATE"
+// blocks of the CA bundle, signing CA first, then the "X509 CRL" blocks,
+// the signing CA's CRL first. Each part ends with a newline.
+func (c *Client) TrustMaterialReader() (io.Reader, error) {
+	caPEM, err := c.GetCACert()
+	if err != nil {
+		return nil, err
+	}
+	crlPEM, err := c.GetCRL()
+	if err != nil {
+		return nil, err
+	}
+	return io.MultiReader(
+		strings.NewReader(withTrailingNewline(caPEM)),
+		strings.NewReader(withTrailingNewline(crlPEM)),
+	), nil
+}
+
+func withTrailingNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
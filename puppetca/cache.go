@@ -0,0 +1,100 @@
+package puppetca
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithStaleIfError caches the results of GetCertStatus and, when a later
+// read fails with a transient error, returns the cached status if it is
+// younger than ttl, with Stale set
+func WithStaleIfError(ttl time.Duration) Option {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.staleCache = &statusCache{ttl: ttl, entries: make(map[string]cachedStatus)}
+		} else {
+			c.staleCache = nil
+		}
+	}
+}
+
+type cachedStatus struct {
+	status  CertStatus
+	fetched time.Time
+}
+
+type statusCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedStatus
+}
+
+func (s *statusCache) put(status CertStatus, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[status.Name] = cachedStatus{status: status, fetched: now}
+}
+
+func (s *statusCache) get(name string, now time.Time) (CertStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[name]
+	if !ok || now.Sub(entry.fetched) > s.ttl {
+		return CertStatus{}, false
+	}
+	return entry.status, true
+}
+
+// GetCertStatus returns the parsed certificate status of a node by its name
+func (c *Client) GetCertStatus(nodename string) (*CertStatus, error) {
+	if err := c.checkCertname(nodename); err != nil {
+		return nil, err
+	}
+	if c.statusBatch != nil {
+		// Snapshot failures fall back to an individual request
+		if status, ok, err := c.statusBatch.get(c, nodename); err == nil && ok {
+			if c.staleCache != nil {
+				c.staleCache.put(status, c.clock())
+			}
+			return &status, nil
+		}
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	var status CertStatus
+	err := c.getDecoded(context.Background(), fmt.Sprintf("certificate_status/%s", nodename), headers, &status)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to retrieve certificate status %s", nodename)
+		if c.staleCache != nil && isTransient(err) {
+			if status, ok := c.staleCache.get(nodename, c.clock()); ok {
+				status.Stale = true
+				return &status, nil
+			}
+		}
+		return nil, err
+	}
+	if c.staleCache != nil {
+		c.staleCache.put(status, c.clock())
+	}
+	return &status, nil
+}
+
+// isTransient returns true for errors worth serving stale data for: network
+// failures, stalled bodies and server-side errors
+func isTransient(err error) bool {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	return errors.Is(err, ErrBodyReadTimeout)
+}
@@ -0,0 +1,88 @@
+package puppetca
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestStaleIfError(t *testing.T) {
+	var (
+		status  int32 = http.StatusOK
+		offline int32
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if code := int(atomic.LoadInt32(&status)); code != http.StatusOK {
+			http.Error(w, "unavailable", code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name":"agent.example.com","state":"signed"}`)
+	}, WithStaleIfError(time.Minute), WithRoundTripper(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if atomic.LoadInt32(&offline) != 0 {
+				return nil, errors.New("connection refused")
+			}
+			return next.RoundTrip(req)
+		})
+	}))
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	fresh, err := c.GetCertStatus("agent.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Stale {
+		t.Error("fresh status marked stale")
+	}
+
+	tests := []struct {
+		name      string
+		status    int32
+		offline   bool
+		age       time.Duration
+		wantStale bool
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, wantStale: true},
+		{name: "network error", offline: true, wantStale: true},
+		{name: "not found is not transient", status: http.StatusNotFound},
+		{name: "forbidden is not transient", status: http.StatusForbidden},
+		{name: "expired entry", status: http.StatusInternalServerError, age: 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&status, tt.status)
+			if tt.offline {
+				atomic.StoreInt32(&offline, 1)
+			}
+			defer atomic.StoreInt32(&offline, 0)
+			c.now = func() time.Time { return now.Add(tt.age) }
+
+			got, err := c.GetCertStatus("agent.example.com")
+			if !tt.wantStale {
+				if err == nil {
+					t.Fatalf("GetCertStatus = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCertStatus: %v", err)
+			}
+			if !got.Stale || got.Name != "agent.example.com" || got.State != "signed" {
+				t.Errorf("GetCertStatus = %+v, want the cached status marked stale", got)
+			}
+		})
+	}
+
+	// Unknown nodes have nothing to fall back to
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	c.now = func() time.Time { return now }
+	if _, err := c.GetCertStatus("other.example.com"); err == nil {
+		t.Error("uncached node served despite a server error")
+	}
+}
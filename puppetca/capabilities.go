@@ -0,0 +1,127 @@
+package puppetca
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned when a cached capability probe shows the CA
+// server lacks a feature a request needs
+var ErrNotSupported = errors.New("not supported by the CA server")
+
+// Capabilities describes the features of a CA server. Fields are either
+// detected by probing the server or assumed from its reported version, as
+// noted on each.
+//
+// Supported content types and admin API presence are not reported: the CA
+// API advertises neither, and the admin API is mounted outside puppet-ca/v1
+// behind its own authorization, so probing it from a CA client would say
+// nothing reliable.
+type Capabilities struct {
+	// ServerVersion is detected from the X-Puppet-Version response header.
+	// It is empty if the server or a proxy strips the header.
+	ServerVersion string
+	// Major is the major version parsed from ServerVersion, or 0 if unknown
+	Major int
+	// Expirations is detected by probing the expirations endpoint
+	Expirations bool
+	// CertTTL, support for cert_ttl in sign requests, is assumed for
+	// Puppet Server 6 and later
+	CertTTL bool
+	// BulkClean, the clean endpoint revoking and deleting many certificates
+	// at once, is assumed for Puppet Server 7 and later
+	BulkClean bool
+}
+
+// capabilitiesCache holds the probed capabilities. probeMu is held across a
+// probe, so concurrent first callers share one; mu only guards caps, so
+// reading the cache never waits for a probe.
+type capabilitiesCache struct {
+	probeMu sync.Mutex
+	mu      sync.Mutex
+	caps    *Capabilities
+}
+
+// Capabilities probes the server and returns its capabilities. The result
+// is cached; later calls return the cached value without probing, and
+// concurrent first calls share a single probe.
+//
+// Methods gated on a capability consult the cache only, never probing on
+// their own: SignRequestWithOptions rejects a CertTTL and CleanCerts avoids
+// the clean endpoint when the cache shows the server lacks them.
+func (c *Client) Capabilities() (*Capabilities, error) {
+	return c.probeCapabilities(context.Background())
+}
+
+func (c *Client) probeCapabilities(ctx context.Context) (*Capabilities, error) {
+	if c.capabilities != nil {
+		c.capabilities.probeMu.Lock()
+		defer c.capabilities.probeMu.Unlock()
+	}
+	if caps, ok := c.CachedCapabilities(); ok {
+		return caps, nil
+	}
+
+	_, version, err := c.getCACert(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to probe server version")
+	}
+	caps := &Capabilities{ServerVersion: version, Major: majorVersion(version)}
+
+	_, err = c.send(ctx, "GET", "expirations", nil, map[string]string{"Accept": "application/json"})
+	switch {
+	case err == nil:
+		caps.Expirations = true
+	case IsNotFound(err), isForbidden(err):
+		// Servers without the endpoint answer 404; older auth.conf rules
+		// deny paths they do not know with 403
+	default:
+		return nil, errors.Wrap(err, "failed to probe expirations endpoint")
+	}
+
+	caps.CertTTL = caps.Major >= 6
+	caps.BulkClean = caps.Major >= 7
+
+	if c.capabilities != nil {
+		c.capabilities.mu.Lock()
+		c.capabilities.caps = caps
+		c.capabilities.mu.Unlock()
+	}
+	copied := *caps
+	return &copied, nil
+}
+
+// CachedCapabilities returns the capabilities found by a previous call to
+// Capabilities, if any
+func (c *Client) CachedCapabilities() (*Capabilities, bool) {
+	if c.capabilities == nil {
+		return nil, false
+	}
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+	if c.capabilities.caps == nil {
+		return nil, false
+	}
+	copied := *c.capabilities.caps
+	return &copied, true
+}
+
+// lacks reports whether cached capabilities of a server with a known
+// version show that has returns false. Unknown servers are given the benefit
+// of the doubt.
+func (c *Client) lacks(has func(*Capabilities) bool) bool {
+	caps, ok := c.CachedCapabilities()
+	return ok && caps.Major > 0 && !has(caps)
+}
+
+func majorVersion(version string) int {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0
+	}
+	return major
+}
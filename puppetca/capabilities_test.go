@@ -0,0 +1,123 @@
+package puppetca
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newVersionedServer returns a client for a server reporting version and
+// denying the expirations endpoint, counting requests per method and path
+func newVersionedServer(t *testing.T, version string) (Client, *sync.Map) {
+	t.Helper()
+	var counts sync.Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n, _ := counts.LoadOrStore(r.Method+" "+r.URL.Path[len("/puppet-ca/v1/"):], new(int32))
+		atomic.AddInt32(n.(*int32), 1)
+		w.Header().Set("X-Puppet-Version", version)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/expirations"):
+			http.Error(w, "denied", http.StatusForbidden)
+		case strings.HasSuffix(r.URL.Path, "/certificate/ca"):
+			// Slow enough for concurrent first callers to overlap
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("ca"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return c, &counts
+}
+
+func count(counts *sync.Map, key string) int32 {
+	n, ok := counts.Load(key)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt32(n.(*int32))
+}
+
+func TestCapabilitiesSingleProbe(t *testing.T) {
+	c, counts := newVersionedServer(t, "6.19.1")
+	if _, ok := c.CachedCapabilities(); ok {
+		t.Fatal("capabilities cached before any probe")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			caps, err := c.Capabilities()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if caps.Major != 6 || caps.Expirations || !caps.CertTTL || caps.BulkClean {
+				t.Errorf("capabilities = %+v", caps)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := count(counts, "GET certificate/ca"); n != 1 {
+		t.Errorf("probed %d times, want 1", n)
+	}
+	if _, ok := c.CachedCapabilities(); !ok {
+		t.Error("capabilities not cached after probe")
+	}
+}
+
+func TestCertTTLGatedOnCachedCapabilities(t *testing.T) {
+	c, counts := newVersionedServer(t, "5.3.10")
+	opts := SignOptions{CertTTL: time.Hour}
+
+	// Nothing cached: the request is sent as is
+	if err := c.SignRequestWithOptions("a", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatal(err)
+	}
+	err := c.SignRequestWithOptions("b", opts)
+	if errors.Cause(err) != ErrNotSupported {
+		t.Errorf("error = %v, want ErrNotSupported", err)
+	}
+	if n := count(counts, "PUT certificate_status/b"); n != 0 {
+		t.Errorf("unsupported sign sent %d requests", n)
+	}
+	if err := c.SignRequest("b"); err != nil {
+		t.Errorf("sign without TTL: %v", err)
+	}
+}
+
+func TestCleanCertsGatedOnCachedCapabilities(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		bulk    bool
+	}{
+		{version: "7.4.0", bulk: true},
+		{version: "6.19.1", bulk: false},
+	} {
+		c, counts := newVersionedServer(t, tt.version)
+		if _, err := c.Capabilities(); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.CleanCerts(context.Background(), []string{"a", "b"}); err != nil {
+			t.Fatalf("%s: %v", tt.version, err)
+		}
+		bulk := count(counts, "PUT clean")
+		single := count(counts, "PUT certificate_status/a") + count(counts, "DELETE certificate_status/b")
+		if tt.bulk && (bulk != 1 || single != 0) {
+			t.Errorf("%s: %d clean and %d per-node requests, want one clean", tt.version, bulk, single)
+		}
+		if !tt.bulk && (bulk != 0 || single != 2) {
+			t.Errorf("%s: %d clean and %d per-node requests, want per-node only", tt.version, bulk, single)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package puppetca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NotBeforeSkewError is returned when a certificate's notBefore lies
+// further in the past than the allowed skew
+type NotBeforeSkewError struct {
+	Nodename  string
+	NotBefore time.Time
+	Skew      time.Duration
+	MaxSkew   time.Duration
+}
+
+func (e *NotBeforeSkewError) Error() string {
+	return fmt.Sprintf("certificate %s notBefore %s is backdated by %s, exceeding max skew of %s",
+		e.Nodename, e.NotBefore.Format(time.RFC3339), e.Skew, e.MaxSkew)
+}
+
+func parseCertPEM(pemStr string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate")
+	}
+	return cert, nil
+}
+
+// GetParsedCertByName returns the parsed certificate of a node by its name
+func (c *Client) GetParsedCertByName(nodename string) (*x509.Certificate, error) {
+	return c.getParsedCertByName(context.Background(), nodename)
+}
+
+func (c *Client) getParsedCertByName(ctx context.Context, nodename string) (*x509.Certificate, error) {
+	pem, err := c.getCertByName(ctx, nodename)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := parseCertPEM(pem)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse certificate %s", nodename)
+	}
+	return cert, nil
+}
+
+// CheckCertNotBeforeSkew returns a *NotBeforeSkewError if the certificate
+// of a node has a notBefore more than maxSkew in the past
+func (c *Client) CheckCertNotBeforeSkew(nodename string, maxSkew time.Duration) error {
+	cert, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return err
+	}
+	skew := c.clock().Sub(cert.NotBefore)
+	if skew > maxSkew {
+		return &NotBeforeSkewError{
+			Nodename:  nodename,
+			NotBefore: cert.NotBefore,
+			Skew:      skew,
+			MaxSkew:   maxSkew,
+		}
+	}
+	return nil
+}
+
+// GetSignedCertChain returns the certificate of a node followed by the CA
+// certificates it chains to, as concatenated PEM in leaf-to-root order
+func (c *Client) GetSignedCertChain(nodename string) (string, error) {
+	leaf, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return "", err
+	}
+	bundle, err := c.GetCACertBundle()
+	if err != nil {
+		return "", err
+	}
+	chain, err := buildChain(leaf, bundle)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to build certificate chain for %s", nodename)
+	}
+
+	var buf bytes.Buffer
+	for _, cert := range chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return "", errors.Wrap(err, "failed to encode certificate chain")
+		}
+	}
+	return buf.String(), nil
+}
+
+// buildChain orders a leaf and the CA certificates it chains to from leaf
+// to root, skipping duplicates and unrelated certificates
+func buildChain(leaf *x509.Certificate, bundle []*x509.Certificate) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{leaf}
+	seen := map[string]bool{string(leaf.Raw): true}
+	current := leaf
+	for !isSelfSigned(current) {
+		var issuer *x509.Certificate
+		for _, candidate := range bundle {
+			if seen[string(candidate.Raw)] {
+				continue
+			}
+			if bytes.Equal(candidate.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(candidate) == nil {
+				issuer = candidate
+				break
+			}
+		}
+		if issuer == nil {
+			if len(chain) == 1 {
+				return nil, fmt.Errorf("issuer %s not found in CA bundle", current.Issuer)
+			}
+			break
+		}
+		chain = append(chain, issuer)
+		seen[string(issuer.Raw)] = true
+		current = issuer
+	}
+	return chain, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// VerifyCertAgainst verifies the certificate of a node against the given
+// roots instead of the client's own CA pool. The intermediate CAs of the CA
+// bundle served by the server are used to build the chain, so a leaf issued
+// by an intermediate verifies against the root alone. The certificate must
+// be valid for all given extended key usages, or for any usage if none are
+// given. The returned error wraps the x509 verification error, such as
+// x509.UnknownAuthorityError or x509.CertificateInvalidError. A nil roots
+// pool is rejected rather than falling back to the system roots.
+func (c *Client) VerifyCertAgainst(nodename string, roots *x509.CertPool, usages ...x509.ExtKeyUsage) error {
+	if roots == nil {
+		return fmt.Errorf("no roots given to verify certificate %s against", nodename)
+	}
+	cert, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return err
+	}
+	bundle, err := c.GetCACertBundle()
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, ca := range bundle {
+		if !isSelfSigned(ca) {
+			intermediates.AddCert(ca)
+		}
+	}
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   c.clock(),
+		KeyUsages:     usages,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "certificate %s failed verification", nodename)
+	}
+	return nil
+}
+
+// GetCertSubject returns the full subject DN of the certificate of a node
+func (c *Client) GetCertSubject(nodename string) (pkix.Name, error) {
+	cert, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	return cert.Subject, nil
+}
+
+// GetCertIssuer returns the full issuer DN of the certificate of a node
+func (c *Client) GetCertIssuer(nodename string) (pkix.Name, error) {
+	cert, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	return cert.Issuer, nil
+}
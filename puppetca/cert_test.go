@@ -0,0 +1,93 @@
+package puppetca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, for building test hierarchies
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func (tc testCert) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}))
+}
+
+// issueTestCert issues a certificate from tmpl signed by parent, or
+// self-signed if parent is nil
+func issueTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.SerialNumber == nil {
+		tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	}
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+	}
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, key: key}
+}
+
+func testCATemplate(cn string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+}
+
+func TestVerifyCertAgainstUsesBundleIntermediates(t *testing.T) {
+	root := issueTestCert(t, testCATemplate("root"), nil)
+	intermediate := issueTestCert(t, testCATemplate("intermediate"), &root)
+	leaf := issueTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "node"}}, &intermediate)
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/certificate/ca") {
+			w.Write([]byte(intermediate.pem() + root.pem()))
+			return
+		}
+		w.Write([]byte(leaf.pem()))
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	if err := c.VerifyCertAgainst("node", roots); err != nil {
+		t.Errorf("verification against the root failed: %v", err)
+	}
+	other := issueTestCert(t, testCATemplate("other"), nil)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.cert)
+	if err := c.VerifyCertAgainst("node", otherRoots); err == nil {
+		t.Error("verification against an unrelated root succeeded")
+	}
+	if err := c.VerifyCertAgainst("node", nil); err == nil {
+		t.Error("verification against nil roots succeeded")
+	}
+}
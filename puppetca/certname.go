@@ -0,0 +1,40 @@
+package puppetca
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidCertname is returned when a certname is rejected by validation
+var ErrInvalidCertname = errors.New("invalid certname")
+
+// certnamePattern is the default set of allowed certnames: an ASCII letter
+// or digit followed by letters, digits, '.', '-' or '_'. Control characters,
+// whitespace and path separators are therefore always rejected.
+var certnamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateCertname is the default certname validator. It returns an error
+// wrapping ErrInvalidCertname if name does not match
+// ^[A-Za-z0-9][A-Za-z0-9._-]*$
+func ValidateCertname(name string) error {
+	if !certnamePattern.MatchString(name) {
+		return errors.Wrapf(ErrInvalidCertname, "certname %q", name)
+	}
+	return nil
+}
+
+// WithCertnameValidator replaces the certname validator applied by methods
+// taking a certname. A nil validator disables validation.
+func WithCertnameValidator(validate func(name string) error) Option {
+	return func(c *Client) {
+		c.validateCertname = validate
+	}
+}
+
+func (c *Client) checkCertname(name string) error {
+	if c.validateCertname == nil {
+		return nil
+	}
+	return c.validateCertname(name)
+}
@@ -0,0 +1,58 @@
+package puppetca
+
+import (
+	"net/http"
+	"sync"
+)
+
+const defaultConcurrency = 4
+
+// forEach calls fn for every name, running at most c.concurrency calls at
+// once, and returns when all calls are done
+func (c *Client) forEach(names []string, fn func(name string)) {
+	n := c.concurrency
+	if n <= 0 {
+		n = defaultConcurrency
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+// WithMaxConcurrentRequests caps the number of requests the client has in
+// flight at once, across all methods. Requests beyond the cap wait for a
+// free slot or for their context to be done; methods taking no context wait
+// until a slot frees. Zero or less means no cap.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.requestSlots = make(chan struct{}, n)
+		} else {
+			c.requestSlots = nil
+		}
+	}
+}
+
+// acquireSlot waits for a request slot and returns the function releasing it
+func (c *Client) acquireSlot(req *http.Request) (func(), error) {
+	if c.requestSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.requestSlots <- struct{}{}:
+		return func() { <-c.requestSlots }, nil
+	case <-req.Context().Done():
+		return nil, &RequestError{Method: req.Method, URL: req.URL.String(), Err: req.Context().Err()}
+	}
+}
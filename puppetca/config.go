@@ -0,0 +1,52 @@
+package puppetca
+
+import "time"
+
+// apiPrefix is the path prefix of the Puppet CA v1 API
+const apiPrefix = "puppet-ca/v1"
+
+// ClientConfig is a read-only snapshot of the effective configuration of a
+// Client. It never contains key or certificate material.
+type ClientConfig struct {
+	BaseURL            string
+	MountPrefix        string
+	APIPrefix          string
+	Timeout            time.Duration
+	BodyReadTimeout    time.Duration
+	InsecureSkipVerify bool
+	ServerName         string
+	ServerFingerprint  string
+	Concurrency        int
+	ExpectContinue     bool
+	UserAgent          string
+}
+
+// WithTimeout sets the overall timeout of each request, including
+// connection, redirects and reading the body. It defaults to no timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// Config returns the effective configuration of the client
+func (c *Client) Config() ClientConfig {
+	cfg := ClientConfig{
+		BaseURL:           c.getBaseURL(),
+		MountPrefix:       c.mountPrefix,
+		APIPrefix:         apiPrefix,
+		BodyReadTimeout:   c.bodyReadTimeout,
+		Concurrency:       c.concurrency,
+		ExpectContinue:    c.expectContinue,
+		ServerFingerprint: c.serverFingerprint,
+		UserAgent:         c.userAgent,
+	}
+	if c.httpClient != nil {
+		cfg.Timeout = c.httpClient.Timeout
+	}
+	if c.transport != nil && c.transport.TLSClientConfig != nil {
+		cfg.InsecureSkipVerify = c.transport.TLSClientConfig.InsecureSkipVerify
+		cfg.ServerName = c.transport.TLSClientConfig.ServerName
+	}
+	return cfg
+}
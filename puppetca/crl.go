@@ -0,0 +1,71 @@
+package puppetca
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DiffCRLs returns the serial numbers revoked in newPEM but not in oldPEM
+// (added) and those revoked in oldPEM but no longer in newPEM (removed),
+// both sorted in ascending order. Only the first CRL of each PEM bundle,
+// the one issued by the signing CA, is compared.
+func DiffCRLs(oldPEM, newPEM string) (added, removed []*big.Int, err error) {
+	oldCRL, err := parseCRLPEM(oldPEM)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse old CRL")
+	}
+	newCRL, err := parseCRLPEM(newPEM)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse new CRL")
+	}
+
+	oldSerials := make(map[string]*big.Int, len(oldCRL.RevokedCertificateEntries))
+	for _, entry := range oldCRL.RevokedCertificateEntries {
+		oldSerials[entry.SerialNumber.String()] = entry.SerialNumber
+	}
+	newSerials := make(map[string]*big.Int, len(newCRL.RevokedCertificateEntries))
+	for _, entry := range newCRL.RevokedCertificateEntries {
+		newSerials[entry.SerialNumber.String()] = entry.SerialNumber
+		if _, ok := oldSerials[entry.SerialNumber.String()]; !ok {
+			added = append(added, entry.SerialNumber)
+		}
+	}
+	for key, serial := range oldSerials {
+		if _, ok := newSerials[key]; !ok {
+			removed = append(removed, serial)
+		}
+	}
+
+	sortSerials(added)
+	sortSerials(removed)
+	return added, removed, nil
+}
+
+// DownloadCRL streams the certificate revocation list to w as served by the
+// CA, without buffering it in memory. The bytes are copied as-is, whether
+// the server sends PEM or DER.
+func (c *Client) DownloadCRL(w io.Writer) error {
+	req, err := c.newHTTPRequest(context.Background(), "GET", "certificate_revocation_list/ca", nil)
+	if err != nil {
+		return err
+	}
+	err = c.roundTrip(req, nil, func(_ *http.Response, body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to download CRL")
+	}
+	return nil
+}
+
+func sortSerials(serials []*big.Int) {
+	sort.Slice(serials, func(i, j int) bool {
+		return serials[i].Cmp(serials[j]) < 0
+	})
+}
@@ -0,0 +1,87 @@
+package puppetca
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testCRL returns a PEM CRL issued by ca revoking the given serials
+func testCRL(t *testing.T, ca testCert, number int64, serials ...int64) string {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, serial := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+}
+
+func TestDiffCRLs(t *testing.T) {
+	ca := issueTestCert(t, testCATemplate("ca"), nil)
+	root := issueTestCert(t, testCATemplate("root"), nil)
+	tests := []struct {
+		name        string
+		old, new    string
+		wantAdded   string
+		wantRemoved string
+		wantErr     bool
+	}{
+		{name: "unchanged", old: testCRL(t, ca, 1, 3, 5), new: testCRL(t, ca, 2, 5, 3), wantAdded: "[]", wantRemoved: "[]"},
+		{name: "added and removed, sorted", old: testCRL(t, ca, 1, 3, 5), new: testCRL(t, ca, 2, 300, 5, 20), wantAdded: "[20 300]", wantRemoved: "[3]"},
+		{name: "from empty", old: testCRL(t, ca, 1), new: testCRL(t, ca, 2, 7), wantAdded: "[7]", wantRemoved: "[]"},
+		// Only the first CRL of a bundle, the signing CA's, is compared
+		{name: "bundle", old: testCRL(t, ca, 1, 1) + testCRL(t, root, 1, 9), new: testCRL(t, ca, 2, 1, 2) + testCRL(t, root, 2), wantAdded: "[2]", wantRemoved: "[]"},
+		{name: "invalid old", old: "garbage", new: testCRL(t, ca, 1), wantErr: true},
+		{name: "invalid new", old: testCRL(t, ca, 1), new: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, err := DiffCRLs(tt.old, tt.new)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("DiffCRLs accepted an invalid CRL")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(added); got != tt.wantAdded {
+				t.Errorf("added = %s, want %s", got, tt.wantAdded)
+			}
+			if got := fmt.Sprint(removed); got != tt.wantRemoved {
+				t.Errorf("removed = %s, want %s", got, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestDownloadCRL(t *testing.T) {
+	ca := issueTestCert(t, testCATemplate("ca"), nil)
+	crl := testCRL(t, ca, 1, 42)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, crl)
+	})
+	var buf bytes.Buffer
+	if err := c.DownloadCRL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != crl {
+		t.Errorf("downloaded %q, want the CRL as served", buf.String())
+	}
+}
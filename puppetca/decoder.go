@@ -0,0 +1,92 @@
+package puppetca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecoderFunc decodes a response body into v
+type DecoderFunc func(body []byte, v interface{}) error
+
+// defaultDecoders are the decoders every client starts with
+var defaultDecoders = map[string]DecoderFunc{
+	"application/json": decodeJSON,
+	"text/pson":        decodeJSON,
+	"application/pson": decodeJSON,
+}
+
+// WithDecoder sets the decoder this client uses for responses with the
+// given media type, replacing any existing one. Parameters such as charset
+// are ignored when matching. Responses with an unregistered or missing
+// content type are decoded as JSON.
+//
+// Decoders apply to responses decoded into Go values, such as certificate
+// statuses. PEM endpoints (CA certificate, CRL, certificates and CSRs) are
+// returned as served, and HTML responses are rejected before decoding.
+func WithDecoder(contentType string, fn DecoderFunc) Option {
+	return func(c *Client) {
+		if fn == nil {
+			c.rejectOption(fmt.Errorf("WithDecoder: nil decoder for %s", contentType))
+			return
+		}
+		if c.decoders == nil {
+			c.decoders = make(map[string]DecoderFunc, len(defaultDecoders)+1)
+			for mt, fn := range defaultDecoders {
+				c.decoders[mt] = fn
+			}
+		}
+		c.decoders[mediaType(contentType)] = fn
+	}
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// decode decodes body into v with the decoder registered for the content
+// type in header
+func (c *Client) decode(header http.Header, body []byte, v interface{}) error {
+	decoders := c.decoders
+	if decoders == nil {
+		decoders = defaultDecoders
+	}
+	fn, ok := decoders[mediaType(header.Get("Content-Type"))]
+	if !ok {
+		fn = decodeJSON
+	}
+	return fn(body, v)
+}
+
+func decodeJSON(body []byte, v interface{}) error {
+	if err := checkNotHTML(http.Header{}, body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(err, "failed to decode JSON")
+	}
+	return nil
+}
+
+// getDecoded performs a GET request bound to ctx and decodes the response
+// into v
+func (c *Client) getDecoded(ctx context.Context, path string, headers map[string]string, v interface{}) error {
+	req, err := c.newHTTPRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, headers)
+	if err != nil {
+		return err
+	}
+	return c.decode(resp.header, resp.body, v)
+}
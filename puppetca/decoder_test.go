@@ -0,0 +1,67 @@
+package puppetca
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecoderPerClient(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/x-names; charset=utf-8")
+		w.Write([]byte("a\nb\n"))
+	}
+	decodeNames := func(body []byte, v interface{}) error {
+		statuses := v.(*[]CertStatus)
+		for _, name := range strings.Fields(string(body)) {
+			*statuses = append(*statuses, CertStatus{Name: name})
+		}
+		return nil
+	}
+	custom := newTestClient(t, handler, WithDecoder("text/x-names", decodeNames))
+	plain := newTestClient(t, handler)
+
+	statuses, err := custom.ListCertStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "a" || statuses[1].Name != "b" {
+		t.Errorf("statuses = %+v", statuses)
+	}
+	// The registration does not leak into other clients, which fall back
+	// to JSON
+	if _, err := plain.ListCertStatuses(); err == nil {
+		t.Error("client without the decoder decoded a non-JSON response")
+	}
+}
+
+func TestDecodeContentTypes(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{contentType: "application/json", body: `[{"name":"a"}]`},
+		{contentType: "text/pson", body: `[{"name":"a"}]`},
+		{contentType: "", body: `[{"name":"a"}]`},
+		{contentType: "text/html; charset=utf-8", body: "<html>login</html>", wantErr: ErrUnexpectedContentType},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.Write([]byte(tt.body))
+		})
+		statuses, err := c.ListCertStatuses()
+		if tt.wantErr != nil {
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("%q: error = %v, want %v", tt.contentType, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(statuses) != 1 || statuses[0].Name != "a" {
+			t.Errorf("%q: statuses = %+v, error = %v", tt.contentType, statuses, err)
+		}
+	}
+}
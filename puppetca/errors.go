@@ -0,0 +1,95 @@
+package puppetca
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnexpectedContentType is returned when the CA, or more likely a proxy
+// in front of it, answers with an HTML page instead of API content
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// RequestError is returned when a request fails before a response is
+// received. It supports both errors.Cause and the standard library's
+// errors.As and errors.Is, so callers can reach the underlying *url.Error,
+// *net.OpError or x509 error.
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("failed to %s URL %s: %v", e.Method, e.URL, e.Err)
+}
+
+// Cause returns the underlying error
+func (e *RequestError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying error
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// HTTPError is returned when the CA answers with an unexpected status code
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	// Body holds the start of the response body, which usually explains
+	// the failure
+	Body string
+	// ClientCN is the common name of the client certificate the request
+	// was authenticated with, if known
+	ClientCN string
+}
+
+func (e *HTTPError) Error() string {
+	if e.StatusCode == http.StatusForbidden && e.ClientCN != "" {
+		return fmt.Sprintf("failed to %s URL %s, got: %s (client authenticated as '%s', which may lack CA permissions in auth.conf)",
+			e.Method, e.URL, e.Status, e.ClientCN)
+	}
+	return fmt.Sprintf("failed to %s URL %s, got: %s", e.Method, e.URL, e.Status)
+}
+
+// maxErrorBody is the number of response body bytes kept in an HTTPError
+const maxErrorBody = 1024
+
+// maxSnippet is the number of response body bytes quoted in errors
+const maxSnippet = 200
+
+// checkNotHTML returns an error wrapping ErrUnexpectedContentType if a
+// response looks like an HTML page. The Puppet CA API never serves HTML, so
+// this usually means an authenticating proxy intercepted the request.
+func checkNotHTML(header http.Header, body []byte) error {
+	contentType := header.Get("Content-Type")
+	trimmed := bytes.TrimSpace(body)
+	prefix := strings.ToLower(string(truncate(trimmed, 15)))
+	if !strings.HasPrefix(strings.ToLower(contentType), "text/html") && !strings.HasPrefix(prefix, "<!doctype") && !strings.HasPrefix(prefix, "<html") {
+		return nil
+	}
+	snippet := string(truncate(trimmed, maxSnippet))
+	return errors.Wrapf(ErrUnexpectedContentType, "got HTML (Content-Type %q), check for an intercepting proxy; body starts with %q", contentType, snippet)
+}
+
+// IsNotFound returns true if err was caused by a 404 response from the CA
+func IsNotFound(err error) bool {
+	httpErr, ok := errors.Cause(err).(*HTTPError)
+	return ok && httpErr.StatusCode == http.StatusNotFound
+}
+
+// isForbidden returns true if err was caused by a 403 response from the CA
+func isForbidden(err error) bool {
+	httpErr, ok := errors.Cause(err).(*HTTPError)
+	return ok && httpErr.StatusCode == http.StatusForbidden
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -0,0 +1,57 @@
+package puppetca
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCheckNotHTML(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantHTML    bool
+	}{
+		{name: "PEM", contentType: "text/plain", body: "-----BEGIN CERTIFICATE-----\n"},
+		{name: "JSON", contentType: "application/json", body: `{"name":"agent"}`},
+		{name: "HTML content type", contentType: "text/html; charset=utf-8", body: "login", wantHTML: true},
+		{name: "HTML content type in upper case", contentType: "Text/HTML", body: "login", wantHTML: true},
+		{name: "doctype without content type", body: "\n  <!DOCTYPE html><html></html>", wantHTML: true},
+		{name: "html tag mislabelled as text", contentType: "text/plain", body: "<HTML><body>login</body></HTML>", wantHTML: true},
+		{name: "empty", contentType: "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			err := checkNotHTML(header, []byte(tt.body))
+			if got := errors.Is(err, ErrUnexpectedContentType); got != tt.wantHTML {
+				t.Errorf("checkNotHTML error = %v, want HTML detected: %v", err, tt.wantHTML)
+			}
+		})
+	}
+}
+
+func TestHTMLResponseRejected(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Please sign in. ", 50) + "</body></html>"
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	})
+	_, err := c.GetCACert()
+	if !errors.Is(err, ErrUnexpectedContentType) {
+		t.Fatalf("GetCACert error = %v, want ErrUnexpectedContentType", err)
+	}
+	if !strings.Contains(err.Error(), "<html><body>Please sign in.") {
+		t.Errorf("error %q does not quote the start of the page", err)
+	}
+	if strings.Contains(err.Error(), page) {
+		t.Error("error quotes the whole page")
+	}
+}
@@ -0,0 +1,98 @@
+package puppetca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ExportOptions holds optional parameters of ExportAllCerts
+type ExportOptions struct {
+	// Force re-exports certificates already present in the output directory
+	Force bool
+}
+
+// ExportReport is the outcome of ExportAllCerts
+type ExportReport struct {
+	Exported int
+	Skipped  int
+	Failed   map[string]error
+}
+
+// ExportAllCerts writes the certificate of every signed node to
+// <dir>/<certname>.pem. Certificates already present are skipped unless
+// opts.Force is set, so an interrupted export resumes where it left off when
+// run again; files are written to a temporary name and renamed into place,
+// so a partial file is never mistaken for a finished one. Requests are
+// bound to ctx; once it is done in-flight fetches are abandoned, no new ones
+// start, and ctx.Err() is returned along with the report so far.
+func (c *Client) ExportAllCerts(ctx context.Context, dir string, opts ExportOptions) (*ExportReport, error) {
+	if err := ensureDir(dir, certDirMode); err != nil {
+		return nil, err
+	}
+	names, err := c.signedCertnames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu     sync.Mutex
+		report = &ExportReport{Failed: make(map[string]error)}
+	)
+	var todo []string
+	paths := make(map[string]string, len(names))
+	for _, name := range names {
+		if err := c.checkCertname(name); err != nil {
+			report.Failed[name] = err
+			continue
+		}
+		path, err := exportPath(dir, name)
+		if err != nil {
+			report.Failed[name] = err
+			continue
+		}
+		paths[name] = path
+		if !opts.Force {
+			if _, err := os.Stat(path); err == nil {
+				report.Skipped++
+				continue
+			}
+		}
+		todo = append(todo, name)
+	}
+
+	c.forEach(todo, func(name string) {
+		if ctx.Err() != nil {
+			return
+		}
+		pem, err := c.getCertByName(ctx, name)
+		if err == nil {
+			err = writeCertFile(paths[name], []byte(pem))
+		} else if ctx.Err() != nil {
+			// Abandoned rather than failed; the next run exports it
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			report.Failed[name] = err
+			return
+		}
+		report.Exported++
+	})
+	return report, ctx.Err()
+}
+
+// exportPath returns the export file of certname in dir. Certnames that
+// would resolve outside dir are rejected, since the certname validator may
+// be disabled or replaced.
+func exportPath(dir, certname string) (string, error) {
+	if certname == "" || certname == "." || certname == ".." || strings.ContainsAny(certname, `/\`) {
+		return "", errors.Wrapf(ErrInvalidCertname, "%q cannot be used as a file name", certname)
+	}
+	return filepath.Join(dir, certname+".pem"), nil
+}
@@ -0,0 +1,99 @@
+package puppetca
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func newExportServer(t *testing.T, names ...string) Client {
+	t.Helper()
+	statuses := make([]CertStatus, len(names))
+	for i, name := range names {
+		statuses[i] = CertStatus{Name: name, State: "signed"}
+	}
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/certificate_statuses/any") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(statuses)
+			return
+		}
+		w.Write([]byte("cert of " + r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]))
+	}, WithCertnameValidator(nil))
+}
+
+func TestExportAllCertsResumes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	c := newExportServer(t, "a", "b")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a.pem left by an earlier, interrupted run
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.pem"), []byte("earlier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.ExportAllCerts(context.Background(), dir, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Exported != 1 || report.Skipped != 1 || len(report.Failed) != 0 {
+		t.Errorf("report = %+v, want 1 exported and 1 skipped", report)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "b.pem")); string(data) != "cert of b" {
+		t.Errorf("b.pem = %q", data)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "a.pem")); string(data) != "earlier" {
+		t.Errorf("a.pem was overwritten without Force: %q", data)
+	}
+
+	report, err = c.ExportAllCerts(context.Background(), dir, ExportOptions{Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Exported != 2 || report.Skipped != 0 {
+		t.Errorf("forced report = %+v, want 2 exported", report)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "a.pem")); string(data) != "cert of a" {
+		t.Errorf("a.pem = %q after Force", data)
+	}
+}
+
+func TestExportAllCertsRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	c := newExportServer(t, "../escape", "sub/name", "ok")
+
+	report, err := c.ExportAllCerts(context.Background(), dir, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../escape", "sub/name"} {
+		if errors.Cause(report.Failed[name]) != ErrInvalidCertname {
+			t.Errorf("%s: error = %v, want ErrInvalidCertname", name, report.Failed[name])
+		}
+	}
+	if report.Exported != 1 {
+		t.Errorf("exported %d, want 1", report.Exported)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.pem")); !os.IsNotExist(err) {
+		t.Error("certificate written outside the export directory")
+	}
+}
+
+func TestExportAllCertsCancelled(t *testing.T) {
+	c := newExportServer(t, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.ExportAllCerts(ctx, t.TempDir(), ExportOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
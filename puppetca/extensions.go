@@ -0,0 +1,173 @@
+package puppetca
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// puppetOIDs maps the short names of Puppet's registered certificate
+// extensions to their OIDs
+var puppetOIDs = map[string]string{
+	"pp_uuid":             "1.3.6.1.4.1.34380.1.1.1",
+	"pp_instance_id":      "1.3.6.1.4.1.34380.1.1.2",
+	"pp_image_name":       "1.3.6.1.4.1.34380.1.1.3",
+	"pp_preshared_key":    "1.3.6.1.4.1.34380.1.1.4",
+	"pp_cost_center":      "1.3.6.1.4.1.34380.1.1.5",
+	"pp_product":          "1.3.6.1.4.1.34380.1.1.6",
+	"pp_project":          "1.3.6.1.4.1.34380.1.1.7",
+	"pp_application":      "1.3.6.1.4.1.34380.1.1.8",
+	"pp_service":          "1.3.6.1.4.1.34380.1.1.9",
+	"pp_employee":         "1.3.6.1.4.1.34380.1.1.10",
+	"pp_created_by":       "1.3.6.1.4.1.34380.1.1.11",
+	"pp_environment":      "1.3.6.1.4.1.34380.1.1.12",
+	"pp_role":             "1.3.6.1.4.1.34380.1.1.13",
+	"pp_software_version": "1.3.6.1.4.1.34380.1.1.14",
+	"pp_department":       "1.3.6.1.4.1.34380.1.1.15",
+	"pp_cluster":          "1.3.6.1.4.1.34380.1.1.16",
+	"pp_provisioner":      "1.3.6.1.4.1.34380.1.1.17",
+	"pp_region":           "1.3.6.1.4.1.34380.1.1.18",
+	"pp_datacenter":       "1.3.6.1.4.1.34380.1.1.19",
+	"pp_zone":             "1.3.6.1.4.1.34380.1.1.20",
+	"pp_network":          "1.3.6.1.4.1.34380.1.1.21",
+	"pp_securitypolicy":   "1.3.6.1.4.1.34380.1.1.22",
+	"pp_cloudplatform":    "1.3.6.1.4.1.34380.1.1.23",
+	"pp_apptier":          "1.3.6.1.4.1.34380.1.1.24",
+	"pp_hostname":         "1.3.6.1.4.1.34380.1.1.25",
+	"pp_authorization":    "1.3.6.1.4.1.34380.1.3.1",
+	"pp_auth_role":        "1.3.6.1.4.1.34380.1.3.13",
+}
+
+// parseOID parses a dotted OID or a Puppet short name such as pp_role
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	if oid, ok := puppetOIDs[s]; ok {
+		s = oid
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
+// extensionValue returns the value of the extension with the given OID and
+// whether it is present. String-typed values are decoded; other values are
+// returned as their raw DER bytes.
+func extensionValue(cert *x509.Certificate, oid asn1.ObjectIdentifier) (string, bool) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+		var s string
+		if rest, err := asn1.Unmarshal(ext.Value, &s); err == nil && len(rest) == 0 {
+			return s, true
+		}
+		return string(ext.Value), true
+	}
+	return "", false
+}
+
+// GroupByExtension fetches all signed certificates and groups their
+// certnames by the value of the given extension, identified by dotted OID
+// or Puppet short name (e.g. pp_role). Certificates lacking the extension
+// are grouped under the empty string. Certnames in each group are sorted.
+func (c *Client) GroupByExtension(oid string) (map[string][]string, error) {
+	id, err := parseOID(oid)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := c.signedCerts()
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]string)
+	for name, cert := range certs {
+		value, _ := extensionValue(cert, id)
+		groups[value] = append(groups[value], name)
+	}
+	for _, names := range groups {
+		sort.Strings(names)
+	}
+	return groups, nil
+}
+
+// signedCerts fetches and parses the certificates of all signed nodes
+func (c *Client) signedCerts() (map[string]*x509.Certificate, error) {
+	names, err := c.signedCertnames(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mu       sync.Mutex
+		certs    = make(map[string]*x509.Certificate, len(names))
+		firstErr error
+	)
+	c.forEach(names, func(name string) {
+		cert, err := c.GetParsedCertByName(name)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		certs[name] = cert
+	})
+	if firstErr != nil {
+		return nil, errors.Wrap(firstErr, "failed to fetch signed certificates")
+	}
+	return certs, nil
+}
+
+func (c *Client) signedCertnames(ctx context.Context) ([]string, error) {
+	statuses, err := c.listCertStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range statuses {
+		if s.State == "signed" {
+			names = append(names, s.Name)
+		}
+	}
+	return names, nil
+}
+
+// ListByTrustedFact fetches all signed certificates and returns the sorted
+// certnames whose extension with the given OID, identified by dotted OID or
+// Puppet short name (e.g. pp_role or pp_auth_role), equals value.
+// Certificates lacking the extension are excluded.
+func (c *Client) ListByTrustedFact(oid, value string) ([]string, error) {
+	id, err := parseOID(oid)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := c.signedCerts()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name, cert := range certs {
+		if v, ok := extensionValue(cert, id); ok && v == value {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,73 @@
+package puppetca
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// File modes used for everything the library writes to disk. The library
+// only writes public material; all file writes must go through
+// writeCertFile so that modes stay consistent.
+const (
+	certFileMode os.FileMode = 0644
+	certDirMode  os.FileMode = 0755
+)
+
+// writeCertFile atomically writes a certificate, CSR or CRL file
+func writeCertFile(path string, data []byte) error {
+	return writeFileAtomic(path, data, certFileMode)
+}
+
+// ensureDir creates dir and its parents with the given mode if missing
+func ensureDir(dir string, mode os.FileMode) error {
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", dir)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// syncs it, sets its mode and renames it over path, so readers never see a
+// partial file and the mode is correct before the file appears. The
+// temporary file is created 0600, so data is never exposed with a wider
+// mode, even briefly.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to sync %s", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return errors.Wrapf(err, "failed to set mode of %s", path)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+
+	// Sync the directory so the rename survives a crash
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to sync directory %s", dir)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return errors.Wrapf(err, "failed to sync directory %s", dir)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package puppetca
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCertFileMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.pem")
+	// An existing file with a wider mode must not keep it
+	if err := ioutil.WriteFile(path, []byte("old"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCertFile(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != certFileMode {
+		t.Errorf("mode = %v, want %v", got, certFileMode)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "new" {
+		t.Errorf("content = %q, want %q", data, "new")
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the written file", len(entries))
+	}
+}
+
+func TestEnsureDirMode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if err := ensureDir(dir, certDirMode); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MkdirAll is subject to the umask, which may only narrow the mode
+	if got := info.Mode().Perm(); got&^certDirMode != 0 {
+		t.Errorf("mode = %v, want at most %v", got, certDirMode)
+	}
+}
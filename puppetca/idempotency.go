@@ -0,0 +1,58 @@
+package puppetca
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// WithIdempotencyKeyHeader attaches an idempotency key under the given
+// header to PUT, POST, PATCH and DELETE requests, so that a gateway can
+// collapse duplicate attempts. The key is a SHA256 hash of the method, URL
+// path and body, so retries of the same operation carry the same key while
+// operations on different nodes or with different payloads never share one.
+// An identical operation repeated on purpose also carries the same key, so
+// the gateway's deduplication window must be shorter than such repeats.
+func WithIdempotencyKeyHeader(headerName string) Option {
+	return func(c *Client) {
+		c.idempotencyHeader = http.CanonicalHeaderKey(headerName)
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case "PUT", "POST", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// idempotencyKey returns the idempotency key of req, reading its body
+// through GetBody so the request body itself is left untouched
+func idempotencyKey(req *http.Request) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, req.Method+"\n"+req.URL.Path+"\n")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		// Not replayable: buffer the body and restore it
+		content, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(content))
+		h.Write(content)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,54 @@
+package puppetca
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}, WithIdempotencyKeyHeader("idempotency-key"))
+
+	for _, op := range []func() error{
+		func() error { return c.SignRequest("a.example.com") },
+		func() error { return c.SignRequest("b.example.com") },
+		func() error { return c.RevokeCertByName("a.example.com") },
+		func() error { return c.SignRequest("a.example.com") },
+	} {
+		if err := op(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.GetCACert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 5 {
+		t.Fatalf("got %d requests, want 5", len(keys))
+	}
+	signA, signB, revokeA, replayA, get := keys[0], keys[1], keys[2], keys[3], keys[4]
+	if signA == "" {
+		t.Fatal("mutating request carries no idempotency key")
+	}
+	if signA == signB {
+		t.Error("signing different nodes shares a key")
+	}
+	if signA == revokeA {
+		t.Error("different payloads for the same node share a key")
+	}
+	if signA != replayA {
+		t.Errorf("replayed sign key = %s, want %s", replayA, signA)
+	}
+	if get != "" {
+		t.Errorf("GET carries idempotency key %s", get)
+	}
+}
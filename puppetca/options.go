@@ -0,0 +1,98 @@
+package puppetca
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures optional Client behaviour
+type Option func(*Client)
+
+// mutateTransport applies fn to the transport of the client, or records an
+// error if the transport is shared with other clients
+func (c *Client) mutateTransport(option string, fn func(*http.Transport)) {
+	if c.shared {
+		c.rejectOption(fmt.Errorf("%s would alter a shared transport; configure the transport before sharing it", option))
+		return
+	}
+	fn(c.transport)
+}
+
+// rejectOption records err as the reason the client cannot be built, unless
+// an earlier option was already rejected
+func (c *Client) rejectOption(err error) {
+	if c.optionErr == nil {
+		c.optionErr = err
+	}
+}
+
+// WithMountPrefix sets an extra path segment inserted between the base URL
+// and the puppet-ca/v1 API prefix, for CAs mounted behind a rewriting proxy
+func WithMountPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.mountPrefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithClock overrides the source of the current time used by time-comparing
+// methods, which defaults to time.Now
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// WithRoundTripper wraps the client transport with middleware. Options are
+// applied in order, so the last one given is the outermost. The innermost
+// transport always carries the client TLS configuration. A nil middleware,
+// or one returning nil, is rejected.
+func WithRoundTripper(middleware func(http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) {
+		if middleware == nil {
+			c.rejectOption(fmt.Errorf("WithRoundTripper: nil middleware"))
+			return
+		}
+		rt := middleware(c.httpClient.Transport)
+		if rt == nil {
+			c.rejectOption(fmt.Errorf("WithRoundTripper: middleware returned a nil RoundTripper"))
+			return
+		}
+		c.httpClient.Transport = rt
+	}
+}
+
+// WithConcurrency sets the maximum number of concurrent requests issued by
+// methods that scan many certificates. It defaults to 4.
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithExpectContinueTimeout makes PUT requests carry an
+// "Expect: 100-continue" header and sets how long the transport waits for
+// the server's 100 Continue before sending the body anyway.
+//
+// Go only sends the Expect header when a request carries it, and this
+// client does not by default, so bodies are sent immediately. A timeout of
+// zero or less keeps that default and also strips any Expect header passed
+// by callers, which helps with proxies that mishandle 100-continue. A
+// positive timeout is rejected by NewClientSharingTransport.
+func WithExpectContinueTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d <= 0 {
+			c.expectContinue = false
+			return
+		}
+		c.expectContinue = true
+		c.mutateTransport("WithExpectContinueTimeout", func(tr *http.Transport) {
+			tr.ExpectContinueTimeout = d
+		})
+	}
+}
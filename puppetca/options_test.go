@@ -0,0 +1,51 @@
+package puppetca
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWithRoundTripperRejectsNil(t *testing.T) {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{}}
+	tests := []struct {
+		name       string
+		middleware func(http.RoundTripper) http.RoundTripper
+		want       string
+	}{
+		{name: "nil middleware", want: "nil middleware"},
+		{name: "nil result", middleware: func(http.RoundTripper) http.RoundTripper { return nil }, want: "returned a nil RoundTripper"},
+	}
+	for _, tt := range tests {
+		_, err := NewClientSharingTransport("https://ca.example.com", tr, WithRoundTripper(tt.middleware))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestWithRoundTripperOrder(t *testing.T) {
+	var order []string
+	wrap := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {}, WithRoundTripper(wrap("inner")), WithRoundTripper(wrap("outer")))
+	if _, err := c.GetCACert(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner" {
+		t.Errorf("middleware order = %s, want outer,inner", got)
+	}
+}
@@ -0,0 +1,136 @@
+package puppetca
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type caSecurityPolicy struct {
+	minKeyBits    int
+	forbidWeakSig bool
+}
+
+// WithCASecurityPolicy makes the constructor reject a CA whose certificates
+// have an RSA key shorter than minKeyBits, or, if forbidWeakSig is
+// set, are signed with MD2, MD5 or SHA-1. The CA PEM given to NewClient is
+// checked, and must not be empty.
+//
+// Clients built with NewClientSharingTransport cannot read the roots of the
+// shared transport, so they make one request at construction, bounded by
+// the client timeout or 30 seconds, and check the CA certificates of the
+// chain TLS verified against those roots. If verification is disabled,
+// there is no such chain and the CA bundle served by the server is checked
+// instead.
+func WithCASecurityPolicy(minKeyBits int, forbidWeakSig bool) Option {
+	return func(c *Client) {
+		c.caPolicy = &caSecurityPolicy{minKeyBits: minKeyBits, forbidWeakSig: forbidWeakSig}
+	}
+}
+
+var weakSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// defaultPolicyTimeout bounds the request made to check the CA security
+// policy of a client sharing a transport, when the client has no timeout
+const defaultPolicyTimeout = 30 * time.Second
+
+// checkCASecurityPolicy enforces the CA security policy, if any, on caPEM,
+// or on the CA certificates trusted through a shared transport
+func (c *Client) checkCASecurityPolicy(caPEM []byte) error {
+	if c.caPolicy == nil {
+		return nil
+	}
+	var (
+		certs []*x509.Certificate
+		err   error
+	)
+	if c.shared {
+		certs, err = c.trustedCACerts()
+	} else if len(bytes.TrimSpace(caPEM)) == 0 {
+		return fmt.Errorf("CA security policy needs the CA certificate, but no CA PEM was given")
+	} else {
+		certs, err = parseCertBundle(string(caPEM))
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to load CA certificate for security policy")
+	}
+	for _, cert := range certs {
+		if err := c.caPolicy.check(cert); err != nil {
+			return errors.Wrapf(err, "CA certificate %s violates security policy", cert.Subject)
+		}
+	}
+	return nil
+}
+
+// trustedCACerts returns the CA certificates of the chains TLS verified when
+// fetching the CA certificate, or the served CA bundle if TLS verified none
+func (c *Client) trustedCACerts() ([]*x509.Certificate, error) {
+	timeout := c.httpClient.Timeout
+	if timeout <= 0 {
+		timeout = defaultPolicyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := c.newHTTPRequest(ctx, "GET", "certificate/ca", nil)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		chains [][]*x509.Certificate
+		served []byte
+	)
+	err = c.roundTrip(req, nil, func(resp *http.Response, body io.Reader) error {
+		if resp.TLS != nil {
+			chains = resp.TLS.VerifiedChains
+		}
+		served, err = ioutil.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return parseCertBundle(string(served))
+	}
+
+	var certs []*x509.Certificate
+	seen := make(map[string]bool)
+	for _, chain := range chains {
+		// A chain of one is a server certificate trusted as a root itself
+		cas := chain
+		if len(chain) > 1 {
+			cas = chain[1:]
+		}
+		for _, cert := range cas {
+			if !seen[string(cert.Raw)] {
+				seen[string(cert.Raw)] = true
+				certs = append(certs, cert)
+			}
+		}
+	}
+	return certs, nil
+}
+
+func (p *caSecurityPolicy) check(cert *x509.Certificate) error {
+	if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < p.minKeyBits {
+		return fmt.Errorf("%d-bit RSA key is shorter than %d bits", key.N.BitLen(), p.minKeyBits)
+	}
+	if p.forbidWeakSig && weakSignatureAlgorithms[cert.SignatureAlgorithm] {
+		return fmt.Errorf("weak signature algorithm %s", cert.SignatureAlgorithm)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package puppetca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rsaTestCA returns a self-signed RSA CA certificate as PEM
+func rsaTestCA(t *testing.T, bits int, sigAlg x509.SignatureAlgorithm) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := testCATemplate("ca")
+	tmpl.SerialNumber = big.NewInt(1)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.SignatureAlgorithm = sigAlg
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCASecurityPolicy(t *testing.T) {
+	certPEM, keyPEM := testClientCert(t)
+	tests := []struct {
+		name    string
+		ca      string
+		wantErr string
+	}{
+		{name: "strong CA", ca: rsaTestCA(t, 2048, x509.SHA256WithRSA)},
+		{name: "1024-bit RSA CA", ca: rsaTestCA(t, 1024, x509.SHA256WithRSA), wantErr: "1024-bit RSA key is shorter than 2048 bits"},
+		{name: "SHA-1 signed CA", ca: rsaTestCA(t, 2048, x509.SHA1WithRSA), wantErr: "SHA1-RSA"},
+		{name: "empty CA", ca: "", wantErr: "no CA PEM was given"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient("https://ca.example.com", keyPEM, certPEM, tt.ca, false, WithCASecurityPolicy(2048, true))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewClient: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewClient error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSharedTransportCASecurityPolicy(t *testing.T) {
+	// The served bundle is not what TLS trusts; only the verified chain counts
+	weakCA := rsaTestCA(t, 1024, x509.SHA256WithRSA)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(weakCA))
+	}))
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name       string
+		tlsConfig  *tls.Config
+		minKeyBits int
+		wantErr    string
+	}{
+		// The httptest certificate is a self-signed 2048-bit RSA root
+		{name: "verified chain passes", tlsConfig: &tls.Config{RootCAs: roots}, minKeyBits: 2048},
+		{name: "verified chain too weak", tlsConfig: &tls.Config{RootCAs: roots}, minKeyBits: 4096, wantErr: "2048-bit RSA key is shorter than 4096 bits"},
+		{name: "unverified falls back to the served bundle", tlsConfig: &tls.Config{InsecureSkipVerify: true}, minKeyBits: 2048, wantErr: "1024-bit RSA key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &http.Transport{TLSClientConfig: tt.tlsConfig}
+			defer tr.CloseIdleConnections()
+			_, err := NewClientSharingTransport(srv.URL, tr, WithCASecurityPolicy(tt.minKeyBits, true), WithTimeout(5*time.Second))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewClientSharingTransport: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewClientSharingTransport error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
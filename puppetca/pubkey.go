@@ -0,0 +1,65 @@
+package puppetca
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// publicKey is implemented by all public key types of the standard library
+type publicKey interface {
+	Equal(crypto.PublicKey) bool
+}
+
+func parsePublicKeyPEM(pemStr string) (publicKey, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM public key found")
+	}
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %s", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse public key")
+	}
+	pub, ok := key.(publicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return pub, nil
+}
+
+// FindCertsByPublicKey returns the sorted certnames of all signed
+// certificates issued for the given PEM public key. Keys are compared by
+// value, e.g. modulus and exponent for RSA or curve and point for ECDSA.
+func (c *Client) FindCertsByPublicKey(pubPEM string) ([]string, error) {
+	pub, err := parsePublicKeyPEM(pubPEM)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := c.signedCerts()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name, cert := range certs {
+		if pub.Equal(cert.PublicKey) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -1,20 +1,54 @@
 package puppetca
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 // Client is a Puppet CA client
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL      *atomic.Value
+	mountPrefix  string
+	httpClient   *http.Client
+	transport    *http.Transport
+	shared       bool
+	now          func() time.Time
+	concurrency  int
+	requestSlots chan struct{}
+	stats        *clientStats
+	staleCache   *statusCache
+	statusBatch  *statusSnapshot
+	capabilities *capabilitiesCache
+	decoders     map[string]DecoderFunc
+
+	bodyReadTimeout time.Duration
+	onTimings       func(RequestTimings)
+
+	expectContinue    bool
+	serverFingerprint string
+	requirePort       bool
+	userAgent         string
+	clientCN          string
+	idempotencyHeader string
+	caPolicy          *caSecurityPolicy
+	logger            *log.Logger
+
+	validateCertname    func(string) error
+	desiredStatePayload func(state string, opts SignOptions) (string, string, error)
+	issuanceLog         func(certname string, cert *x509.Certificate)
+
+	optionErr error
 }
 
 func isFile(str string) bool {
@@ -24,8 +58,44 @@ func isFile(str string) bool {
 	return strings.HasSuffix(str, ".pem") || strings.HasSuffix(str, ".cer") || strings.HasSuffix(str, ".key") || strings.HasPrefix(str, "/") || strings.HasPrefix(str, "./") || strings.HasPrefix(str, "../")
 }
 
-// NewClient returns a new Client
-func NewClient(baseURL, keyStr, certStr, caStr string, ignoreSsl bool) (c Client, err error) {
+// NewClient returns a new Client. The base URL must use https; if it has
+// no port, 8140 is used unless WithRequirePort is given.
+func NewClient(baseURL, keyStr, certStr, caStr string, ignoreSsl bool, opts ...Option) (c Client, err error) {
+	tr, caPEM, err := newTransport(keyStr, certStr, caStr, ignoreSsl)
+	if err != nil {
+		return c, err
+	}
+	return newClient(baseURL, tr, caPEM, false, opts)
+}
+
+// NewClientSharingTransport returns a new Client using an existing
+// transport, typically one built by NewTransport, so that several clients
+// share a single connection pool.
+//
+// The TLS identity belongs to the transport: every client sharing it
+// authenticates with the same client certificate. Clients needing distinct
+// identities must use distinct transports. For the same reason, options that
+// would alter the transport, namely WithServerName, WithServerFingerprint
+// and WithExpectContinueTimeout, are rejected rather than changing it under
+// the other clients; set ServerName, VerifyPeerCertificate or
+// ExpectContinueTimeout on the transport before sharing it instead.
+func NewClientSharingTransport(baseURL string, shared *http.Transport, opts ...Option) (Client, error) {
+	if shared == nil || shared.TLSClientConfig == nil {
+		return Client{}, fmt.Errorf("shared transport has no TLS configuration")
+	}
+	return newClient(baseURL, shared, nil, true, opts)
+}
+
+// NewTransport returns an HTTP transport authenticating with the given
+// client key and certificate and trusting the given CA, suitable for
+// NewClientSharingTransport
+func NewTransport(keyStr, certStr, caStr string, ignoreSsl bool) (*http.Transport, error) {
+	tr, _, err := newTransport(keyStr, certStr, caStr, ignoreSsl)
+	return tr, err
+}
+
+// newTransport is NewTransport, also returning the loaded CA PEM
+func newTransport(keyStr, certStr, caStr string, ignoreSsl bool) (tr *http.Transport, caCert []byte, err error) {
 	// Load client cert
 	var cert tls.Certificate
 	if isFile(certStr) {
@@ -37,23 +107,22 @@ func NewClient(baseURL, keyStr, certStr, caStr string, ignoreSsl bool) (c Client
 		cert, err = tls.LoadX509KeyPair(certStr, keyStr)
 		if err != nil {
 			err = errors.Wrapf(err, "failed to load client cert from file %s", certStr)
-			return c, err
+			return nil, nil, err
 		}
 	} else {
 		if isFile(keyStr) {
 			err = fmt.Errorf("cert is a string but key points to a file")
-			return c, err
+			return nil, nil, err
 		}
 
 		cert, err = tls.X509KeyPair([]byte(certStr), []byte(keyStr))
 		if err != nil {
 			err = errors.Wrapf(err, "failed to load client cert from string")
-			return c, err
+			return nil, nil, err
 		}
 	}
 
 	// Load CA cert
-	var caCert []byte
 	if isFile(caStr) {
 		caCert, err = ioutil.ReadFile(caStr)
 		if err != nil {
@@ -72,16 +141,56 @@ func NewClient(baseURL, keyStr, certStr, caStr string, ignoreSsl bool) (c Client
 		RootCAs:            caCertPool,
 		InsecureSkipVerify: ignoreSsl,
 	}
-	tr := &http.Transport{TLSClientConfig: tlsConfig}
+	tr = &http.Transport{TLSClientConfig: tlsConfig}
+
+	return tr, caCert, nil
+}
+
+func newClient(baseURL string, tr *http.Transport, caPEM []byte, shared bool, opts []Option) (c Client, err error) {
 	httpClient := &http.Client{Transport: tr}
-	c = Client{baseURL, httpClient}
+	c = Client{
+		baseURL:             &atomic.Value{},
+		httpClient:          httpClient,
+		transport:           tr,
+		shared:              shared,
+		now:                 time.Now,
+		userAgent:           defaultUserAgent,
+		clientCN:            clientCertCN(tr.TLSClientConfig),
+		concurrency:         defaultConcurrency,
+		stats:               &clientStats{},
+		capabilities:        &capabilitiesCache{},
+		validateCertname:    ValidateCertname,
+		desiredStatePayload: desiredStatePayload,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.optionErr != nil {
+		return c, c.optionErr
+	}
+	if err = c.setBaseURL(baseURL); err != nil {
+		return c, err
+	}
+	if err = c.checkTLSConfig(tr.TLSClientConfig); err != nil {
+		return c, err
+	}
+	if err = c.checkCASecurityPolicy(caPEM); err != nil {
+		return c, err
+	}
 
 	return
 }
 
 // GetCertByName returns the certificate of a node by its name
 func (c *Client) GetCertByName(nodename string) (string, error) {
-	pem, err := c.Get(fmt.Sprintf("certificate/%s", nodename), nil)
+	return c.getCertByName(context.Background(), nodename)
+}
+
+func (c *Client) getCertByName(ctx context.Context, nodename string) (string, error) {
+	if err := c.checkCertname(nodename); err != nil {
+		return "", err
+	}
+	pem, err := c.send(ctx, "GET", fmt.Sprintf("certificate/%s", nodename), nil, nil)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to retrieve certificate %s", nodename)
 	}
@@ -90,7 +199,14 @@ func (c *Client) GetCertByName(nodename string) (string, error) {
 
 // GetCertStatusByName returns the certificate status info of a node by its name
 func (c *Client) GetCertStatusByName(nodename string) (string, error) {
-	certInfo, err := c.Get(fmt.Sprintf("certificate_status/%s", nodename), nil)
+	return c.getCertStatusByName(context.Background(), nodename)
+}
+
+func (c *Client) getCertStatusByName(ctx context.Context, nodename string) (string, error) {
+	if err := c.checkCertname(nodename); err != nil {
+		return "", err
+	}
+	certInfo, err := c.send(ctx, "GET", fmt.Sprintf("certificate_status/%s", nodename), nil, nil)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to retrieve certificate %s", nodename)
 	}
@@ -99,20 +215,51 @@ func (c *Client) GetCertStatusByName(nodename string) (string, error) {
 
 // DeleteCertByName deletes the certificate of a given node
 func (c *Client) DeleteCertByName(nodename string) error {
-	_, err := c.Delete(fmt.Sprintf("certificate_status/%s", nodename), nil)
+	return c.deleteCertByName(context.Background(), nodename)
+}
+
+func (c *Client) deleteCertByName(ctx context.Context, nodename string) error {
+	if err := c.checkCertname(nodename); err != nil {
+		return err
+	}
+	_, err := c.send(ctx, "DELETE", fmt.Sprintf("certificate_status/%s", nodename), nil, nil)
 	if err != nil {
 		return errors.Wrapf(err, "failed to delete certificate %s", nodename)
 	}
 	return nil
 }
 
+// DeleteCertByNameResult deletes the certificate of a given node and
+// reports whether it existed beforehand. A missing certificate is not
+// an error.
+func (c *Client) DeleteCertByNameResult(nodename string) (existed bool, err error) {
+	if err = c.checkCertname(nodename); err != nil {
+		return false, err
+	}
+	_, err = c.Delete(fmt.Sprintf("certificate_status/%s", nodename), nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to delete certificate %s", nodename)
+	}
+	return true, nil
+}
+
 // SubmitRequest submits a CSR
 func (c *Client) SubmitRequest(nodename string, pem string) error {
+	return c.submitRequest(context.Background(), nodename, pem)
+}
+
+func (c *Client) submitRequest(ctx context.Context, nodename string, pem string) error {
+	if err := c.checkCertname(nodename); err != nil {
+		return err
+	}
 	// Content-Type: text/plain
 	headers := map[string]string{
 		"Content-Type": "text/plain",
 	}
-	_, err := c.Put(fmt.Sprintf("certificate_request/%s", nodename), pem, headers)
+	_, err := c.send(ctx, "PUT", fmt.Sprintf("certificate_request/%s", nodename), strings.NewReader(pem), headers)
 	if err != nil {
 		return errors.Wrapf(err, "failed to submit CSR %s", nodename)
 	}
@@ -121,48 +268,64 @@ func (c *Client) SubmitRequest(nodename string, pem string) error {
 
 // SignRequest signs a CSR
 func (c *Client) SignRequest(nodename string) error {
-	action := "{\"desired_state\":\"signed\"}"
-	headers := map[string]string{
-		"Content-Type": "text/pson",
+	return c.SignRequestWithOptions(nodename, SignOptions{})
+}
+
+// RevokeCertByName revokes the certificate of a given node
+func (c *Client) RevokeCertByName(nodename string) error {
+	return c.revokeCertByName(context.Background(), nodename)
+}
+
+func (c *Client) revokeCertByName(ctx context.Context, nodename string) error {
+	if err := c.checkCertname(nodename); err != nil {
+		return err
 	}
-	_, err := c.Put(fmt.Sprintf("certificate_status/%s", nodename), action, headers)
-	if err != nil {
-		return errors.Wrapf(err, "failed to sign CSR %s", nodename)
+	if err := c.putDesiredState(ctx, nodename, "revoked", SignOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to revoke certificate %s", nodename)
 	}
 	return nil
 }
 
 // Get performs a GET request
 func (c *Client) Get(path string, headers map[string]string) (string, error) {
-	req, err := c.newHTTPRequest("GET", path)
-	if err != nil {
-		return "", err
-	}
-	return c.Do(req, headers)
+	return c.send(context.Background(), "GET", path, nil, headers)
 }
 
 // Put performs a PUT request
 func (c *Client) Put(path, data string, headers map[string]string) (string, error) {
-	req, err := c.newHTTPRequest("PUT", path)
-	if err != nil {
-		return "", err
-	}
-	req.Body = ioutil.NopCloser(strings.NewReader(data))
-	return c.Do(req, headers)
+	// A strings.Reader body lets http.NewRequest set ContentLength, so the
+	// payload is not sent chunked
+	return c.send(context.Background(), "PUT", path, strings.NewReader(data), headers)
 }
 
 // Delete performs a DELETE request
 func (c *Client) Delete(path string, headers map[string]string) (string, error) {
-	req, err := c.newHTTPRequest("DELETE", path)
+	return c.send(context.Background(), "DELETE", path, nil, headers)
+}
+
+// send performs a request bound to ctx
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, headers map[string]string) (string, error) {
+	req, err := c.newHTTPRequest(ctx, method, path, body)
 	if err != nil {
 		return "", err
 	}
 	return c.Do(req, headers)
 }
 
-func (c *Client) newHTTPRequest(method, path string) (*http.Request, error) {
-	uri := fmt.Sprintf("%s/puppet-ca/v1/%s", c.baseURL, path)
-	req, err := http.NewRequest(method, uri, nil)
+func (c *Client) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+func (c *Client) newHTTPRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	uri := c.getBaseURL()
+	if c.mountPrefix != "" {
+		uri += "/" + c.mountPrefix
+	}
+	uri += "/" + apiPrefix + "/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create http request for URL %s", uri)
 	}
@@ -171,21 +334,100 @@ func (c *Client) newHTTPRequest(method, path string) (*http.Request, error) {
 
 // Do performs an HTTP request
 func (c *Client) Do(req *http.Request, headers map[string]string) (string, error) {
+	resp, err := c.do(req, headers)
+	if err != nil {
+		return "", err
+	}
+	return string(resp.body), nil
+}
+
+// response is a fully read HTTP response
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+func (c *Client) do(req *http.Request, headers map[string]string) (*response, error) {
+	var r *response
+	err := c.roundTrip(req, headers, func(resp *http.Response, body io.Reader) error {
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read body response from %s", req.URL)
+		}
+		if err := checkNotHTML(resp.Header, content); err != nil {
+			return errors.Wrapf(err, "failed to %s URL %s", req.Method, req.URL)
+		}
+		r = &response{statusCode: resp.StatusCode, header: resp.Header, body: content}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// roundTrip sends req and, on success, passes the response and its body to
+// consume while the body is still open
+func (c *Client) roundTrip(req *http.Request, headers map[string]string, consume func(*http.Response, io.Reader) error) error {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if isMutating(req.Method) {
+		// Whatever the outcome, the change may have reached the server
+		defer c.statusBatch.invalidate()
+	}
+	if c.idempotencyHeader != "" && isMutating(req.Method) {
+		key, err := idempotencyKey(req)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compute idempotency key for %s", req.URL)
+		}
+		req.Header.Set(c.idempotencyHeader, key)
+	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	resp, err := c.httpClient.Do(req)
+	if c.expectContinue && req.Method == "PUT" {
+		req.Header.Set("Expect", "100-continue")
+	} else {
+		req.Header.Del("Expect")
+	}
+	release, err := c.acquireSlot(req)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to %s URL %s", req.Method, req.URL)
+		return err
 	}
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return "", fmt.Errorf("failed to %s URL %s, got: %s", req.Method, req.URL, resp.Status)
+	var timings *RequestTimings
+	defer func() {
+		release()
+		// Reported once the slot is free, so the callback may make requests
+		c.report(timings)
+	}()
+	c.stats.begin()
+	body := &countingReader{}
+	defer func() { c.stats.end(body.n) }()
+
+	req, cancel := c.withBodyDeadline(req)
+	defer cancel()
+	req, trace := c.trace(req)
+	defer func() { timings = trace.finish(req) }()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &RequestError{Method: req.Method, URL: req.URL.String(), Err: err}
 	}
 	defer resp.Body.Close()
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to read body response from %s")
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		content, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &HTTPError{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(content)),
+			ClientCN:   c.clientCN,
+		}
 	}
-
-	return string(content), nil
+	watched, stop := c.watchBody(resp.Body, cancel)
+	defer stop()
+	body.r = watched
+	return consume(resp, body)
 }
@@ -0,0 +1,82 @@
+package puppetca
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient starts a TLS server running handler and returns a client
+// trusting it
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) Client {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	certPEM, keyPEM := testClientCert(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	c, err := NewClient(srv.URL, keyPEM, certPEM, caPEM, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPutSetsContentLength(t *testing.T) {
+	var (
+		gotLength   int64
+		gotEncoding []string
+		gotBody     string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotLength = r.ContentLength
+		gotEncoding = r.TransferEncoding
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := c.SignRequest("agent.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if gotLength != int64(len(gotBody)) || gotLength == 0 {
+		t.Errorf("ContentLength = %d, want %d", gotLength, len(gotBody))
+	}
+	if len(gotEncoding) != 0 {
+		t.Errorf("TransferEncoding = %v, want none", gotEncoding)
+	}
+}
+
+func TestRequestURLJoinsSlashes(t *testing.T) {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{}}
+	tests := []struct {
+		baseURL string
+		prefix  string
+		path    string
+		want    string
+	}{
+		{baseURL: "https://ca.example.com:8140", path: "certificate/ca", want: "https://ca.example.com:8140/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://ca.example.com:8140/", path: "certificate/ca", want: "https://ca.example.com:8140/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://ca.example.com:8140//", path: "/certificate/ca", want: "https://ca.example.com:8140/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://ca.example.com:8140", path: "//certificate/ca", want: "https://ca.example.com:8140/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://ca.example.com/", path: "/certificate/ca", want: "https://ca.example.com:8140/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://proxy.example.com:443/puppet/", path: "/certificate/ca", want: "https://proxy.example.com:443/puppet/puppet-ca/v1/certificate/ca"},
+		{baseURL: "https://proxy.example.com:443/", prefix: "/ca/", path: "/certificate/ca", want: "https://proxy.example.com:443/ca/puppet-ca/v1/certificate/ca"},
+	}
+	for _, tt := range tests {
+		c, err := NewClientSharingTransport(tt.baseURL, tr, WithMountPrefix(tt.prefix))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.baseURL, err)
+		}
+		req, err := c.newHTTPRequest(context.Background(), "GET", tt.path, nil)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.baseURL, tt.path, err)
+		}
+		if got := req.URL.String(); got != tt.want {
+			t.Errorf("base %q, prefix %q, path %q: URL = %s, want %s", tt.baseURL, tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package puppetca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoPendingCSR is returned when a node has no pending certificate request
+var ErrNoPendingCSR = errors.New("no pending CSR")
+
+// ReissueDiff describes how a certificate issued from the pending CSR of a
+// node would differ from its current certificate
+type ReissueDiff struct {
+	Nodename        string
+	OldSubject      string
+	NewSubject      string
+	AddedDNSNames   []string
+	RemovedDNSNames []string
+	AddedIPs        []string
+	RemovedIPs      []string
+	KeyChanged      bool
+}
+
+// Changed returns true if the reissued certificate would differ in subject
+// or subject alternative names
+func (d *ReissueDiff) Changed() bool {
+	return d.OldSubject != d.NewSubject ||
+		len(d.AddedDNSNames) > 0 || len(d.RemovedDNSNames) > 0 ||
+		len(d.AddedIPs) > 0 || len(d.RemovedIPs) > 0
+}
+
+// GetCertRequestByName returns the pending CSR of a node by its name
+func (c *Client) GetCertRequestByName(nodename string) (string, error) {
+	return c.getCertRequestByName(context.Background(), nodename)
+}
+
+func (c *Client) getCertRequestByName(ctx context.Context, nodename string) (string, error) {
+	if err := c.checkCertname(nodename); err != nil {
+		return "", err
+	}
+	pem, err := c.send(ctx, "GET", fmt.Sprintf("certificate_request/%s", nodename), nil, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve CSR %s", nodename)
+	}
+	return pem, nil
+}
+
+// GetParsedCertRequestByName returns the parsed pending CSR of a node. It
+// returns an error wrapping ErrNoPendingCSR if the node has none.
+func (c *Client) GetParsedCertRequestByName(nodename string) (*x509.CertificateRequest, error) {
+	pem, err := c.GetCertRequestByName(nodename)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, errors.Wrapf(ErrNoPendingCSR, "node %s", nodename)
+		}
+		return nil, err
+	}
+	csr, err := parseCSRPEM(pem)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse CSR %s", nodename)
+	}
+	return csr, nil
+}
+
+// PreviewReissue compares the current certificate of a node with its
+// pending CSR. It returns an error wrapping ErrNoPendingCSR if there is
+// nothing to compare against.
+func (c *Client) PreviewReissue(nodename string) (*ReissueDiff, error) {
+	csr, err := c.GetParsedCertRequestByName(nodename)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := c.GetParsedCertByName(nodename)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ReissueDiff{
+		Nodename:   nodename,
+		OldSubject: cert.Subject.String(),
+		NewSubject: csr.Subject.String(),
+	}
+	diff.AddedDNSNames, diff.RemovedDNSNames = diffStrings(cert.DNSNames, csr.DNSNames)
+	diff.AddedIPs, diff.RemovedIPs = diffStrings(ipStrings(cert.IPAddresses), ipStrings(csr.IPAddresses))
+
+	oldKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal public key of certificate %s", nodename)
+	}
+	newKey, err := x509.MarshalPKIXPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal public key of CSR %s", nodename)
+	}
+	diff.KeyChanged = !bytes.Equal(oldKey, newKey)
+
+	return diff, nil
+}
+
+func parseCSRPEM(pemStr string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no PEM certificate request found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate request")
+	}
+	return csr, nil
+}
+
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+// diffStrings returns the sorted elements only in b (added) and only in
+// a (removed)
+func diffStrings(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// ErrSkipped marks a node left untouched by a bulk operation
+var ErrSkipped = errors.New("skipped")
+
+// ErrReissueIncomplete is matched, with errors.Is, by the error returned
+// when a reissue fails after the certificate of the node was revoked
+var ErrReissueIncomplete = errors.New("reissue incomplete")
+
+// ReissueIncompleteError is returned when a reissue fails after revocation,
+// leaving the node with a revoked certificate or none at all. CSR holds the
+// request saved beforehand, so the reissue can be finished by submitting
+// and signing it.
+type ReissueIncompleteError struct {
+	Nodename string
+	Step     string
+	CSR      string
+	Err      error
+}
+
+func (e *ReissueIncompleteError) Error() string {
+	return fmt.Sprintf("reissue of %s incomplete: %s failed after revocation: %v", e.Nodename, e.Step, e.Err)
+}
+
+// Is reports whether target is ErrReissueIncomplete
+func (e *ReissueIncompleteError) Is(target error) bool {
+	return target == ErrReissueIncomplete
+}
+
+// Cause returns the underlying error
+func (e *ReissueIncompleteError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error
+func (e *ReissueIncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// Reissue replaces the certificate of a node with one signed from its
+// pending CSR: the CSR is saved, the current certificate revoked and
+// cleaned, and the CSR resubmitted and signed. It returns an error wrapping
+// ErrNoPendingCSR, without touching the node, if there is no pending CSR.
+//
+// Reissue is not atomic. Once the certificate is revoked, the node cannot
+// authenticate until the new one is signed; if cleaning, resubmitting or
+// signing fails in that window, a *ReissueIncompleteError carrying the
+// saved CSR is returned.
+func (c *Client) Reissue(nodename string) error {
+	return c.reissue(context.Background(), nodename)
+}
+
+// reissue binds the steps up to revocation to ctx. The steps after it run
+// to completion regardless, since stopping there would strand the node.
+func (c *Client) reissue(ctx context.Context, nodename string) error {
+	csr, err := c.getCertRequestByName(ctx, nodename)
+	if err != nil {
+		if IsNotFound(err) {
+			return errors.Wrapf(ErrNoPendingCSR, "node %s", nodename)
+		}
+		return err
+	}
+	if err := c.revokeCertByName(ctx, nodename); err != nil {
+		return err
+	}
+	incomplete := func(step string, err error) error {
+		return &ReissueIncompleteError{Nodename: nodename, Step: step, CSR: csr, Err: err}
+	}
+	if err := c.DeleteCertByName(nodename); err != nil {
+		return incomplete("clean", err)
+	}
+	if err := c.SubmitRequest(nodename, csr); err != nil {
+		return incomplete("submit CSR", err)
+	}
+	if err := c.SignRequest(nodename); err != nil {
+		return incomplete("sign", err)
+	}
+	return nil
+}
+
+// ReissueExpiring reissues every certificate expiring within window and
+// returns the result for each node. Nodes without a pending CSR are skipped,
+// since cleaning them would leave them without a certificate; their result
+// wraps ErrSkipped. The returned error is non-nil if any reissue failed.
+// Once ctx is done no further node is revoked, but a node already revoked
+// is still cleaned, resubmitted and signed. The result of a node left
+// mid-reissue is a *ReissueIncompleteError holding its CSR.
+func (c *Client) ReissueExpiring(ctx context.Context, window time.Duration) (map[string]error, error) {
+	expiring, err := c.expiringWithin(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(expiring))
+	for i, s := range expiring {
+		names[i] = s.Name
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]error, len(names))
+	failed := 0
+	c.forEach(names, func(name string) {
+		err := ctx.Err()
+		if err == nil {
+			err = c.reissue(ctx, name)
+			if errors.Cause(err) == ErrNoPendingCSR {
+				err = errors.Wrapf(ErrSkipped, "node %s has no pending CSR", name)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		results[name] = err
+		if err != nil && errors.Cause(err) != ErrSkipped {
+			failed++
+		}
+	})
+	if failed > 0 {
+		return results, fmt.Errorf("failed to reissue %d of %d expiring certificates", failed, len(names))
+	}
+	return results, nil
+}
@@ -0,0 +1,122 @@
+package puppetca
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const testCSR = "-----BEGIN CERTIFICATE REQUEST-----\nMIIB\n-----END CERTIFICATE REQUEST-----\n"
+
+// newReissueServer serves a node with a pending CSR, failing the reissue
+// step named by fail, and records each step taken
+func newReissueServer(t *testing.T, fail string) (Client, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		steps []string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var step string
+		switch {
+		case r.Method == "GET" && strings.Contains(r.URL.Path, "/certificate_request/"):
+			step = "get CSR"
+		case r.Method == "PUT" && strings.Contains(string(body), `"revoked"`):
+			step = "revoke"
+		case r.Method == "DELETE":
+			step = "clean"
+		case r.Method == "PUT" && strings.Contains(r.URL.Path, "/certificate_request/"):
+			step = "submit CSR"
+			if string(body) != testCSR {
+				t.Errorf("submitted CSR %q, want the saved one", body)
+			}
+		case r.Method == "PUT" && strings.Contains(string(body), `"signed"`):
+			step = "sign"
+		default:
+			t.Errorf("unexpected request %s %s %s", r.Method, r.URL.Path, body)
+		}
+		mu.Lock()
+		steps = append(steps, step)
+		mu.Unlock()
+		switch {
+		case step == fail && step == "get CSR":
+			http.NotFound(w, r)
+		case step == fail:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case step == "get CSR":
+			fmt.Fprint(w, testCSR)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), steps...)
+	}
+}
+
+func TestReissue(t *testing.T) {
+	c, steps := newReissueServer(t, "")
+	if err := c.Reissue("agent.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	want := "get CSR, revoke, clean, submit CSR, sign"
+	if got := strings.Join(steps(), ", "); got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+}
+
+func TestReissueWithoutPendingCSR(t *testing.T) {
+	c, steps := newReissueServer(t, "get CSR")
+	err := c.Reissue("agent.example.com")
+	if errors.Cause(err) != ErrNoPendingCSR {
+		t.Fatalf("Reissue error = %v, want ErrNoPendingCSR", err)
+	}
+	if errors.Is(err, ErrReissueIncomplete) {
+		t.Error("untouched node reported as an incomplete reissue")
+	}
+	if got := steps(); len(got) != 1 {
+		t.Errorf("steps = %v, want the node left untouched", got)
+	}
+}
+
+func TestReissueFailingBeforeRevocation(t *testing.T) {
+	c, _ := newReissueServer(t, "revoke")
+	err := c.Reissue("agent.example.com")
+	if err == nil || errors.Is(err, ErrReissueIncomplete) {
+		t.Errorf("Reissue error = %v, want a plain revocation failure", err)
+	}
+}
+
+func TestReissueIncomplete(t *testing.T) {
+	for _, step := range []string{"clean", "submit CSR", "sign"} {
+		t.Run(step, func(t *testing.T) {
+			c, steps := newReissueServer(t, step)
+			err := c.Reissue("agent.example.com")
+			if !errors.Is(err, ErrReissueIncomplete) {
+				t.Fatalf("Reissue error = %v, want ErrReissueIncomplete", err)
+			}
+			var incomplete *ReissueIncompleteError
+			if !errors.As(err, &incomplete) {
+				t.Fatalf("Reissue error %T is not a *ReissueIncompleteError", err)
+			}
+			if incomplete.Nodename != "agent.example.com" || incomplete.Step != step || incomplete.CSR != testCSR {
+				t.Errorf("error = %+v, want step %q and the saved CSR", incomplete, step)
+			}
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+				t.Errorf("error does not unwrap to the failed request: %v", err)
+			}
+			if got := steps(); got[len(got)-1] != step {
+				t.Errorf("steps = %v, want none after the failed %s", got, step)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package puppetca
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SelfTestStep is the outcome of one operation of a self-test
+type SelfTestStep struct {
+	Name             string
+	Duration         time.Duration
+	Err              error
+	PermissionDenied bool
+}
+
+// SelfTestReport lists the steps run by a self-test, in order
+type SelfTestReport struct {
+	Steps []SelfTestStep
+}
+
+// OK returns true if every step succeeded
+func (r *SelfTestReport) OK() bool {
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfTest checks connectivity and permissions by reading the CA
+// certificate, CRL and certificate statuses. If testCertname is not empty,
+// it also submits, signs, fetches and cleans a throwaway CSR for that
+// certname. To avoid touching a real node, SelfTest refuses to run against
+// a certname already known to the CA; the throwaway certificate is cleaned
+// even when a later step fails or ctx is done. Every other request is bound
+// to ctx.
+func (c *Client) SelfTest(ctx context.Context, testCertname string) (*SelfTestReport, error) {
+	if testCertname != "" {
+		if err := c.checkCertname(testCertname); err != nil {
+			return nil, err
+		}
+		_, err := c.getCertStatusByName(ctx, testCertname)
+		if err == nil {
+			return nil, fmt.Errorf("refusing to self-test against existing certname %s", testCertname)
+		}
+		if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	report := &SelfTestReport{}
+	record := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		step := SelfTestStep{Name: name, Duration: time.Since(start), Err: err}
+		if httpErr, ok := errors.Cause(err).(*HTTPError); ok && httpErr.StatusCode == http.StatusForbidden {
+			step.PermissionDenied = true
+		}
+		report.Steps = append(report.Steps, step)
+		return err == nil
+	}
+	run := func(name string, fn func() error) bool {
+		if err := ctx.Err(); err != nil {
+			report.Steps = append(report.Steps, SelfTestStep{Name: name, Err: err})
+			return false
+		}
+		return record(name, fn)
+	}
+
+	run("get CA certificate", func() error {
+		_, _, err := c.getCACert(ctx)
+		return err
+	})
+	run("get CRL", func() error {
+		_, err := c.getCRL(ctx)
+		return err
+	})
+	run("list certificate statuses", func() error {
+		_, err := c.listCertStatuses(ctx)
+		return err
+	})
+	if testCertname == "" {
+		return report, nil
+	}
+
+	csr, err := selfTestCSR(testCertname)
+	if err != nil {
+		return nil, err
+	}
+	if !run("submit CSR", func() error { return c.submitRequest(ctx, testCertname, csr) }) {
+		return report, nil
+	}
+	// Cleanup runs even if ctx is cancelled mid-test, so it is not bound to ctx
+	defer record("clean certificate", func() error {
+		return c.deleteCertByName(context.Background(), testCertname)
+	})
+	if !run("sign CSR", func() error { return c.signRequestWithOptions(ctx, testCertname, SignOptions{}) }) {
+		return report, nil
+	}
+	run("get certificate", func() error {
+		_, err := c.getParsedCertByName(ctx, testCertname)
+		return err
+	})
+	return report, nil
+}
+
+func selfTestCSR(certname string) (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate self-test key")
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: certname},
+	}, key)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create self-test CSR")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
@@ -0,0 +1,302 @@
+package puppetca
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SignOptions holds optional parameters of a sign request
+type SignOptions struct {
+	// CertTTL is the requested validity of the issued certificate. Zero
+	// uses the CA's default. It is rejected with ErrNotSupported if cached
+	// Capabilities show the server does not support it.
+	CertTTL time.Duration
+	// CertExtensions are custom extensions to embed in the issued
+	// certificate, keyed by dotted OID or Puppet short name (e.g. pp_role).
+	// Only servers supporting cert_extensions in the desired-state payload
+	// honour them.
+	CertExtensions map[string]string
+}
+
+// desiredState is the payload of a certificate_status PUT
+type desiredState struct {
+	DesiredState   string            `json:"desired_state"`
+	CertTTL        int64             `json:"cert_ttl,omitempty"`
+	CertExtensions map[string]string `json:"cert_extensions,omitempty"`
+}
+
+func desiredStatePayload(state string, opts SignOptions) (body, contentType string, err error) {
+	payload := desiredState{
+		DesiredState: state,
+		CertTTL:      int64(opts.CertTTL / time.Second),
+	}
+	if len(opts.CertExtensions) > 0 {
+		payload.CertExtensions = make(map[string]string, len(opts.CertExtensions))
+		for name, value := range opts.CertExtensions {
+			oid, err := parseOID(name)
+			if err != nil {
+				return "", "", errors.Wrap(err, "invalid cert extension")
+			}
+			payload.CertExtensions[oid.String()] = value
+		}
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to encode desired state")
+	}
+	return string(b), "text/pson", nil
+}
+
+// WithDesiredStatePayloadFunc replaces the function building the body and
+// content type of the desired-state PUT used to sign and revoke, for servers
+// expecting a non-standard payload. The default sends
+// {"desired_state":state,...} as text/pson.
+func WithDesiredStatePayloadFunc(fn func(state string, opts SignOptions) (body, contentType string, err error)) Option {
+	return func(c *Client) {
+		if fn != nil {
+			c.desiredStatePayload = fn
+		}
+	}
+}
+
+// WithIssuanceLog calls fn with every certificate issued by a sign request
+// made through this client. The issued certificate is fetched after each
+// successful sign only when this option is set, retrying as
+// SignCertByNameAndFetch does. A certificate that cannot be fetched does not
+// fail the sign; it is reported through WithLogger and fn is not called.
+func WithIssuanceLog(fn func(certname string, cert *x509.Certificate)) Option {
+	return func(c *Client) {
+		c.issuanceLog = fn
+	}
+}
+
+// SignRequestWithOptions signs a CSR with the given options
+func (c *Client) SignRequestWithOptions(nodename string, opts SignOptions) error {
+	return c.signRequestWithOptions(context.Background(), nodename, opts)
+}
+
+func (c *Client) signRequestWithOptions(ctx context.Context, nodename string, opts SignOptions) error {
+	if err := c.sign(ctx, nodename, opts); err != nil {
+		return err
+	}
+	if c.issuanceLog != nil {
+		pem, err := c.fetchIssuedCert(ctx, nodename)
+		c.logIssuance(nodename, pem, err)
+	}
+	return nil
+}
+
+// sign signs a CSR without notifying the issuance log
+func (c *Client) sign(ctx context.Context, nodename string, opts SignOptions) error {
+	if err := c.checkCertname(nodename); err != nil {
+		return err
+	}
+	if opts.CertTTL > 0 && c.lacks(func(caps *Capabilities) bool { return caps.CertTTL }) {
+		return errors.Wrapf(ErrNotSupported, "cannot sign CSR %s with a cert TTL", nodename)
+	}
+	err := c.putDesiredState(ctx, nodename, "signed", opts)
+	if err != nil {
+		if httpErr, ok := errors.Cause(err).(*HTTPError); ok && len(opts.CertExtensions) > 0 && httpErr.StatusCode == http.StatusBadRequest {
+			return errors.Wrapf(err, "CA rejected cert extensions for CSR %s: %s", nodename, httpErr.Body)
+		}
+		return errors.Wrapf(err, "failed to sign CSR %s", nodename)
+	}
+	return nil
+}
+
+// logIssuance passes the certificate issued to nodename, or the error met
+// fetching it, to the issuance log. Errors are logged rather than returned,
+// since the sign itself succeeded.
+func (c *Client) logIssuance(nodename, pem string, err error) {
+	if c.issuanceLog == nil {
+		return
+	}
+	var cert *x509.Certificate
+	if err == nil {
+		cert, err = parseCertPEM(pem)
+	}
+	if err != nil {
+		c.logf("puppetca: signed CSR %s but could not record it in the issuance log: %v", nodename, err)
+		return
+	}
+	c.issuanceLog(nodename, cert)
+}
+
+func (c *Client) putDesiredState(ctx context.Context, nodename, state string, opts SignOptions) error {
+	payload := c.desiredStatePayload
+	if payload == nil {
+		payload = desiredStatePayload
+	}
+	action, contentType, err := payload(state, opts)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Content-Type": contentType,
+	}
+	_, err = c.send(ctx, "PUT", fmt.Sprintf("certificate_status/%s", nodename), strings.NewReader(action), headers)
+	return err
+}
+
+// ErrCertNotYetAvailable is returned when a CSR was signed but the issued
+// certificate could not be fetched within the retry window
+var ErrCertNotYetAvailable = errors.New("signed but certificate not yet fetchable")
+
+// Retry schedule used while the issued certificate is not yet served
+const (
+	fetchAttempts     = 5
+	fetchInitialDelay = 100 * time.Millisecond
+)
+
+// SignCertByNameAndFetch signs the CSR of a node and returns the issued
+// certificate PEM. Fetching is retried with backoff while the CA does not
+// serve the certificate yet; if it never does, the returned error wraps
+// ErrCertNotYetAvailable, distinguishing it from a sign failure.
+func (c *Client) SignCertByNameAndFetch(nodename string) (string, error) {
+	ctx := context.Background()
+	if err := c.sign(ctx, nodename, SignOptions{}); err != nil {
+		return "", err
+	}
+	pem, err := c.fetchIssuedCert(ctx, nodename)
+	c.logIssuance(nodename, pem, err)
+	return pem, err
+}
+
+// fetchIssuedCert fetches the certificate of a just-signed node, retrying
+// with backoff while the CA does not serve it yet
+func (c *Client) fetchIssuedCert(ctx context.Context, nodename string) (string, error) {
+	delay := fetchInitialDelay
+	var err error
+	for attempt := 0; attempt < fetchAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return "", errors.Wrapf(ctx.Err(), "signed CSR %s but stopped fetching certificate", nodename)
+			}
+			delay *= 2
+		}
+		var pem string
+		pem, err = c.getCertByName(ctx, nodename)
+		if err == nil {
+			return pem, nil
+		}
+		if !IsNotFound(err) {
+			return "", errors.Wrapf(err, "signed CSR %s but failed to fetch certificate", nodename)
+		}
+	}
+	return "", errors.Wrapf(ErrCertNotYetAvailable, "node %s after %d attempts: %v", nodename, fetchAttempts, err)
+}
+
+// ErrMissingSANs is returned when an issued certificate lacks required
+// subject alternative names
+var ErrMissingSANs = errors.New("issued certificate is missing required SANs")
+
+// SignAndRequireSANs signs the CSR of a node, fetches the issued
+// certificate and returns an error wrapping ErrMissingSANs if any of
+// requiredSANs is not among its DNS, IP, URI or email SANs. The certificate
+// is left in place on mismatch; see SignAndRequireSANsOrRevoke.
+func (c *Client) SignAndRequireSANs(nodename string, requiredSANs []string) error {
+	return c.signAndRequireSANs(nodename, requiredSANs, false)
+}
+
+// SignAndRequireSANsOrRevoke is SignAndRequireSANs, revoking the issued
+// certificate if it lacks required SANs
+func (c *Client) SignAndRequireSANsOrRevoke(nodename string, requiredSANs []string) error {
+	return c.signAndRequireSANs(nodename, requiredSANs, true)
+}
+
+func (c *Client) signAndRequireSANs(nodename string, requiredSANs []string, revoke bool) error {
+	pem, err := c.SignCertByNameAndFetch(nodename)
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertPEM(pem)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse certificate %s", nodename)
+	}
+
+	missing := missingSANs(cert, requiredSANs)
+	if len(missing) == 0 {
+		return nil
+	}
+	err = errors.Wrapf(ErrMissingSANs, "certificate %s lacks %s", nodename, strings.Join(missing, ", "))
+	if revoke {
+		if revokeErr := c.RevokeCertByName(nodename); revokeErr != nil {
+			return errors.Wrapf(err, "revocation also failed: %v", revokeErr)
+		}
+		return errors.Wrap(err, "certificate revoked")
+	}
+	return err
+}
+
+// missingSANs returns the elements of required absent from the SANs of
+// cert. Each is matched by its form: IP addresses by value, so 2001:db8::1
+// matches 2001:0db8::1; URIs with the scheme and host case-insensitive;
+// email addresses with the domain case-insensitive; anything else as a DNS
+// name, case-insensitively.
+func missingSANs(cert *x509.Certificate, required []string) []string {
+	var missing []string
+	for _, san := range required {
+		if !hasSAN(cert, san) {
+			missing = append(missing, san)
+		}
+	}
+	return missing
+}
+
+func hasSAN(cert *x509.Certificate, san string) bool {
+	if ip := net.ParseIP(san); ip != nil {
+		for _, certIP := range cert.IPAddresses {
+			if ip.Equal(certIP) {
+				return true
+			}
+		}
+		return false
+	}
+	if u, err := url.Parse(san); err == nil && u.Scheme != "" && u.Opaque == "" {
+		want := normalizeURI(u)
+		for _, certURI := range cert.URIs {
+			if normalizeURI(certURI) == want {
+				return true
+			}
+		}
+		return false
+	}
+	if strings.Contains(san, "@") {
+		want := normalizeEmail(san)
+		for _, email := range cert.EmailAddresses {
+			if normalizeEmail(email) == want {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, san) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeURI(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	normalized.Host = strings.ToLower(u.Host)
+	return normalized.String()
+}
+
+func normalizeEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	return email[:at] + strings.ToLower(email[at:])
+}
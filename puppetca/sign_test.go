@@ -0,0 +1,53 @@
+package puppetca
+
+import (
+	"crypto/x509"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMissingSANs(t *testing.T) {
+	uri, err := url.Parse("spiffe://Example.com/agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{
+		DNSNames:       []string{"Agent.Example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+		URIs:           []*url.URL{uri},
+		EmailAddresses: []string{"admin@Example.com"},
+	}
+	tests := []struct {
+		name     string
+		required []string
+		want     []string
+	}{
+		{name: "DNS name in another case", required: []string{"agent.example.COM"}},
+		{name: "absent DNS name", required: []string{"other.example.com"}, want: []string{"other.example.com"}},
+		{name: "IPv4", required: []string{"192.0.2.1"}},
+		{name: "absent IPv4", required: []string{"192.0.2.2"}, want: []string{"192.0.2.2"}},
+		{name: "IPv6 in non-canonical form", required: []string{"2001:0db8:0::1"}},
+		{name: "IPv4-mapped IPv6", required: []string{"::ffff:192.0.2.1"}},
+		{name: "absent IPv6", required: []string{"2001:db8::2"}, want: []string{"2001:db8::2"}},
+		{name: "URI with host in another case", required: []string{"SPIFFE://example.com/agent"}},
+		{name: "URI path is case-sensitive", required: []string{"spiffe://example.com/Agent"}, want: []string{"spiffe://example.com/Agent"}},
+		{name: "email with domain in another case", required: []string{"admin@example.com"}},
+		{name: "email local part is case-sensitive", required: []string{"Admin@example.com"}, want: []string{"Admin@example.com"}},
+		{name: "several missing in order", required: []string{"b.example.com", "agent.example.com", "a.example.com"}, want: []string{"b.example.com", "a.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingSANs(cert, tt.required); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingSANs(%v) = %v, want %v", tt.required, got, tt.want)
+			}
+		})
+	}
+
+	// An IP SAN outside IPAddresses is missing even if listed as a DNS name
+	dnsOnly := &x509.Certificate{DNSNames: []string{"192.0.2.1"}}
+	if got := missingSANs(dnsOnly, []string{"192.0.2.1"}); len(got) != 1 {
+		t.Errorf("IP listed only as a DNS name matched: missing = %v", got)
+	}
+}
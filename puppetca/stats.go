@@ -0,0 +1,54 @@
+package puppetca
+
+import "sync/atomic"
+
+// ClientStats is a snapshot of the request counters of a Client
+type ClientStats struct {
+	TotalRequests    int64
+	InFlightRequests int64
+	BytesRead        int64
+}
+
+type clientStats struct {
+	totalRequests    int64
+	inFlightRequests int64
+	bytesRead        int64
+}
+
+// Stats returns the request counters of the client
+func (c *Client) Stats() ClientStats {
+	if c.stats == nil {
+		return ClientStats{}
+	}
+	return ClientStats{
+		TotalRequests:    atomic.LoadInt64(&c.stats.totalRequests),
+		InFlightRequests: atomic.LoadInt64(&c.stats.inFlightRequests),
+		BytesRead:        atomic.LoadInt64(&c.stats.bytesRead),
+	}
+}
+
+// ResetStats zeroes the total requests and bytes read counters. The
+// in-flight count is a gauge and is left untouched.
+func (c *Client) ResetStats() {
+	if c.stats == nil {
+		return
+	}
+	atomic.StoreInt64(&c.stats.totalRequests, 0)
+	atomic.StoreInt64(&c.stats.bytesRead, 0)
+}
+
+func (s *clientStats) begin() {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.totalRequests, 1)
+	atomic.AddInt64(&s.inFlightRequests, 1)
+}
+
+func (s *clientStats) end(bytesRead int64) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.inFlightRequests, -1)
+	atomic.AddInt64(&s.bytesRead, bytesRead)
+}
@@ -0,0 +1,179 @@
+package puppetca
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// statusTimeLayout is the timestamp format used by the certificate_status endpoints
+const statusTimeLayout = "2006-01-02T15:04:05MST"
+
+// CertStatus is the status of a certificate as reported by the CA
+type CertStatus struct {
+	Name                    string            `json:"name"`
+	State                   string            `json:"state"`
+	Fingerprint             string            `json:"fingerprint"`
+	Fingerprints            map[string]string `json:"fingerprints"`
+	DNSAltNames             []string          `json:"dns_alt_names"`
+	SubjectAltNames         []string          `json:"subject_alt_names"`
+	AuthorizationExtensions map[string]string `json:"authorization_extensions"`
+	SerialNumber            int64             `json:"serial_number"`
+	NotBefore               string            `json:"not_before"`
+	NotAfter                string            `json:"not_after"`
+
+	// Stale is set when the status was served from cache because the CA
+	// could not be reached
+	Stale bool `json:"-"`
+}
+
+// NotBeforeTime returns the parsed not_before timestamp
+func (s CertStatus) NotBeforeTime() (time.Time, error) {
+	return time.Parse(statusTimeLayout, s.NotBefore)
+}
+
+// NotAfterTime returns the parsed not_after timestamp
+func (s CertStatus) NotAfterTime() (time.Time, error) {
+	return time.Parse(statusTimeLayout, s.NotAfter)
+}
+
+// SortField selects the key used to sort certificate statuses
+type SortField int
+
+const (
+	// SortByCertname sorts alphabetically by certname
+	SortByCertname SortField = iota
+	// SortByState sorts alphabetically by state, then by certname
+	SortByState
+	// SortByExpiry sorts by not_after, soonest first; statuses without
+	// a parsable not_after (e.g. pending requests) sort last
+	SortByExpiry
+)
+
+// ListCertStatuses returns the status of all certificates, in the order
+// returned by the API
+func (c *Client) ListCertStatuses() ([]CertStatus, error) {
+	return c.listCertStatuses(context.Background())
+}
+
+func (c *Client) listCertStatuses(ctx context.Context) ([]CertStatus, error) {
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	var statuses []CertStatus
+	if err := c.getDecoded(ctx, "certificate_statuses/any", headers, &statuses); err != nil {
+		return nil, errors.Wrap(err, "failed to list certificate statuses")
+	}
+	return statuses, nil
+}
+
+// ListCertStatusesSorted returns the status of all certificates, stably
+// sorted by the given field
+func (c *Client) ListCertStatusesSorted(by SortField) ([]CertStatus, error) {
+	statuses, err := c.ListCertStatuses()
+	if err != nil {
+		return nil, err
+	}
+	SortCertStatuses(statuses, by)
+	return statuses, nil
+}
+
+// SortCertStatuses stably sorts statuses in place by the given field
+func SortCertStatuses(statuses []CertStatus, by SortField) {
+	switch by {
+	case SortByState:
+		sort.SliceStable(statuses, func(i, j int) bool {
+			if statuses[i].State != statuses[j].State {
+				return statuses[i].State < statuses[j].State
+			}
+			return statuses[i].Name < statuses[j].Name
+		})
+	case SortByExpiry:
+		sort.SliceStable(statuses, func(i, j int) bool {
+			ti, erri := statuses[i].NotAfterTime()
+			tj, errj := statuses[j].NotAfterTime()
+			if erri != nil || errj != nil {
+				return erri == nil && errj != nil
+			}
+			return ti.Before(tj)
+		})
+	default:
+		sort.SliceStable(statuses, func(i, j int) bool {
+			return statuses[i].Name < statuses[j].Name
+		})
+	}
+}
+
+// ExpiringWithin returns the status of signed certificates expiring within
+// window from now
+func (c *Client) ExpiringWithin(window time.Duration) ([]CertStatus, error) {
+	return c.expiringWithin(context.Background(), window)
+}
+
+func (c *Client) expiringWithin(ctx context.Context, window time.Duration) ([]CertStatus, error) {
+	statuses, err := c.listCertStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return expiringBefore(statuses, c.clock().Add(window)), nil
+}
+
+func expiringBefore(statuses []CertStatus, deadline time.Time) []CertStatus {
+	var expiring []CertStatus
+	for _, s := range statuses {
+		if s.State != "signed" {
+			continue
+		}
+		notAfter, err := s.NotAfterTime()
+		if err == nil && notAfter.Before(deadline) {
+			expiring = append(expiring, s)
+		}
+	}
+	return expiring
+}
+
+// Summary aggregates the certificate statuses of the CA
+type Summary struct {
+	Total  int
+	Counts map[string]int
+	// Pending holds the most recent pending requests, newest first
+	Pending []CertStatus
+}
+
+// StatusSummary lists statuses once and returns the count per state along
+// with up to sampleSize of the most recent pending requests, ordered by
+// not_before. Requests without a parsable not_before sort last.
+func (c *Client) StatusSummary(sampleSize int) (*Summary, error) {
+	statuses, err := c.ListCertStatuses()
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{
+		Total:   len(statuses),
+		Counts:  make(map[string]int),
+		Pending: []CertStatus{},
+	}
+	for _, s := range statuses {
+		summary.Counts[s.State]++
+		if s.State == "requested" {
+			summary.Pending = append(summary.Pending, s)
+		}
+	}
+	sort.SliceStable(summary.Pending, func(i, j int) bool {
+		ti, erri := summary.Pending[i].NotBeforeTime()
+		tj, errj := summary.Pending[j].NotBeforeTime()
+		if erri != nil || errj != nil {
+			return erri == nil && errj != nil
+		}
+		return ti.After(tj)
+	})
+	if sampleSize < 0 {
+		sampleSize = 0
+	}
+	if len(summary.Pending) > sampleSize {
+		summary.Pending = summary.Pending[:sampleSize]
+	}
+	return summary, nil
+}
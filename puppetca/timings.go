@@ -0,0 +1,112 @@
+package puppetca
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTimings is the client-side breakdown of a request's latency. Phases
+// that did not happen, such as DNS and TLS on a reused connection, are zero.
+type RequestTimings struct {
+	Method     string
+	URL        string
+	ReusedConn bool
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	// FirstByte is the time from sending the request to the first
+	// response byte, i.e. mostly server processing time
+	FirstByte time.Duration
+	BodyRead  time.Duration
+	Total     time.Duration
+}
+
+// WithRequestTimings calls fn with the phase timings of every request, once
+// the request is done and its concurrency slot released. Timing capture is
+// only enabled when this option is set.
+func WithRequestTimings(fn func(RequestTimings)) Option {
+	return func(c *Client) {
+		c.onTimings = fn
+	}
+}
+
+// timingTrace records the phase timestamps of a request. Its mutex guards
+// the fields, since httptrace hooks may run on dial goroutines that outlive
+// the request, such as one losing a connection race.
+type timingTrace struct {
+	mu                                                   sync.Mutex
+	start, dnsStart, dnsDone, connectStart, connectDone  time.Time
+	tlsStart, tlsDone, wroteRequest, firstByte, bodyDone time.Time
+	reused                                               bool
+}
+
+// mark sets *field to the current time
+func (t *timingTrace) mark(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*field = time.Now()
+}
+
+// trace returns req instrumented with a timingTrace if timings are captured
+func (c *Client) trace(req *http.Request) (*http.Request, *timingTrace) {
+	if c.onTimings == nil {
+		return req, nil
+	}
+	t := &timingTrace{start: time.Now()}
+	ct := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct)), t
+}
+
+// finish marks the end of the request and returns a snapshot of its
+// timings, or nil if t is nil
+func (t *timingTrace) finish(req *http.Request) *RequestTimings {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bodyDone = time.Now()
+	return &RequestTimings{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		ReusedConn: t.reused,
+		DNS:        span(t.dnsStart, t.dnsDone),
+		Connect:    span(t.connectStart, t.connectDone),
+		TLS:        span(t.tlsStart, t.tlsDone),
+		FirstByte:  span(t.wroteRequest, t.firstByte),
+		BodyRead:   span(t.firstByte, t.bodyDone),
+		Total:      span(t.start, t.bodyDone),
+	}
+}
+
+// report passes the timings of a finished request to the callback
+func (c *Client) report(timings *RequestTimings) {
+	if timings == nil || c.onTimings == nil {
+		return
+	}
+	c.onTimings(*timings)
+}
+
+func span(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
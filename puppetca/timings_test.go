@@ -0,0 +1,65 @@
+package puppetca
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestTimingsConcurrent(t *testing.T) {
+	var reports int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {},
+		WithRequestTimings(func(rt RequestTimings) {
+			atomic.AddInt32(&reports, 1)
+			if rt.Total <= 0 {
+				t.Errorf("Total = %v, want > 0", rt.Total)
+			}
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetCACert(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&reports); n != 8 {
+		t.Errorf("got %d reports, want 8", n)
+	}
+}
+
+func TestRequestTimingsCallbackMayRequest(t *testing.T) {
+	var (
+		c      Client
+		nested int32
+	)
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {},
+		WithMaxConcurrentRequests(1),
+		WithRequestTimings(func(RequestTimings) {
+			if atomic.CompareAndSwapInt32(&nested, 0, 1) {
+				if _, err := c.GetCRL(); err != nil {
+					t.Error(err)
+				}
+			}
+		}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetCACert()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request from timings callback deadlocked on the concurrency slot")
+	}
+}
@@ -0,0 +1,83 @@
+package puppetca
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// WithServerName sets the name used to verify the CA's certificate when it
+// differs from the host of the base URL. It cannot be combined with
+// ignoreSsl, since no verification would take place, nor used with
+// NewClientSharingTransport.
+func WithServerName(name string) Option {
+	return func(c *Client) {
+		c.mutateTransport("WithServerName", func(tr *http.Transport) {
+			tr.TLSClientConfig.ServerName = name
+		})
+	}
+}
+
+// WithServerFingerprint pins the SHA256 fingerprint of the CA server's
+// certificate, in hex with or without colons. The pin is checked on every
+// handshake even when ignoreSsl is set, so skipping chain verification
+// never disables it. It cannot be used with NewClientSharingTransport.
+func WithServerFingerprint(fingerprint string) Option {
+	return func(c *Client) {
+		c.mutateTransport("WithServerFingerprint", func(tr *http.Transport) {
+			c.serverFingerprint = normalizeFingerprint(fingerprint)
+			tr.TLSClientConfig.VerifyPeerCertificate = verifyFingerprint(c.serverFingerprint)
+		})
+	}
+}
+
+func verifyFingerprint(want string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		if got := fmt.Sprintf("%X", sum[:]); got != want {
+			return fmt.Errorf("server certificate fingerprint %s does not match pinned %s", got, want)
+		}
+		return nil
+	}
+}
+
+// ClientCertCN returns the common name of the client certificate the
+// client authenticates with, or an empty string if it has none
+func (c *Client) ClientCertCN() string {
+	return c.clientCN
+}
+
+// clientCertCN returns the common name of the first client certificate of cfg
+func clientCertCN(cfg *tls.Config) string {
+	if cfg == nil || len(cfg.Certificates) == 0 {
+		return ""
+	}
+	cert := cfg.Certificates[0]
+	if cert.Leaf != nil {
+		return cert.Leaf.Subject.CommonName
+	}
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return leaf.Subject.CommonName
+}
+
+// checkTLSConfig rejects TLS settings that would silently nullify each other
+func (c *Client) checkTLSConfig(cfg *tls.Config) error {
+	if cfg.InsecureSkipVerify && cfg.ServerName != "" {
+		return fmt.Errorf("server name %s has no effect when ignoreSsl is set; use a fingerprint pin instead", cfg.ServerName)
+	}
+	if c.serverFingerprint != "" && !isSHA256Fingerprint(c.serverFingerprint) {
+		return fmt.Errorf("invalid server fingerprint %s", c.serverFingerprint)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package puppetca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClientCert returns a self-signed client certificate and key as PEM
+func testClientCert(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agent.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestTLSVerification(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ca")
+	}))
+	// Rejected handshakes are expected; keep them out of the test output
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	certPEM, keyPEM := testClientCert(t)
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	otherCA, _ := testClientCert(t)
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	pin := fmt.Sprintf("%X", sum[:])
+	wrongPin := strings.Repeat("AB", sha256.Size)
+
+	tests := []struct {
+		name       string
+		ca         string
+		ignoreSsl  bool
+		opts       []Option
+		wantNewErr string
+		wantGetErr string
+	}{
+		{name: "verified", ca: serverCA},
+		{name: "verified with server name", ca: serverCA, opts: []Option{WithServerName("example.com")}},
+		{name: "verified with wrong server name", ca: serverCA, opts: []Option{WithServerName("ca.invalid")}, wantGetErr: "certificate"},
+		{name: "untrusted CA", ca: otherCA, wantGetErr: "certificate"},
+		{name: "verified with pin", ca: serverCA, opts: []Option{WithServerFingerprint(pin)}},
+		{name: "verified with wrong pin", ca: serverCA, opts: []Option{WithServerFingerprint(wrongPin)}, wantGetErr: "does not match pinned"},
+		{name: "ignoreSsl", ca: otherCA, ignoreSsl: true},
+		{name: "ignoreSsl with server name", ca: otherCA, ignoreSsl: true, opts: []Option{WithServerName("example.com")}, wantNewErr: "has no effect when ignoreSsl is set"},
+		{name: "ignoreSsl with pin", ca: otherCA, ignoreSsl: true, opts: []Option{WithServerFingerprint(strings.ToLower(pin))}},
+		{name: "ignoreSsl with wrong pin", ca: otherCA, ignoreSsl: true, opts: []Option{WithServerFingerprint(wrongPin)}, wantGetErr: "does not match pinned"},
+		{name: "malformed pin", ca: serverCA, opts: []Option{WithServerFingerprint("AB:CD")}, wantNewErr: "invalid server fingerprint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(srv.URL, keyPEM, certPEM, tt.ca, tt.ignoreSsl, tt.opts...)
+			if tt.wantNewErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantNewErr) {
+					t.Fatalf("NewClient error = %v, want %q", err, tt.wantNewErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			_, err = c.GetCACert()
+			if tt.wantGetErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantGetErr) {
+					t.Fatalf("GetCACert error = %v, want %q", err, tt.wantGetErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCACert: %v", err)
+			}
+		})
+	}
+}
+
+func TestSharedTransportRejectsTransportOptions(t *testing.T) {
+	certPEM, keyPEM := testClientCert(t)
+	tr, err := NewTransport(keyPEM, certPEM, certPEM, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string]Option{
+		"WithServerName":            WithServerName("example.com"),
+		"WithServerFingerprint":     WithServerFingerprint(strings.Repeat("AB", sha256.Size)),
+		"WithExpectContinueTimeout": WithExpectContinueTimeout(time.Second),
+	}
+	for name, opt := range opts {
+		if _, err := NewClientSharingTransport("https://ca.example.com", tr, opt); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: error = %v, want rejection", name, err)
+		}
+	}
+	if tr.TLSClientConfig.ServerName != "" || tr.TLSClientConfig.VerifyPeerCertificate != nil || tr.ExpectContinueTimeout != 0 {
+		t.Error("shared transport was modified")
+	}
+}
@@ -0,0 +1,38 @@
+package puppetca
+
+import "time"
+
+// TriageReport lists what needs an operator's attention
+type TriageReport struct {
+	// Pending holds requests awaiting signature
+	Pending []CertStatus
+	// Expiring holds signed certificates expiring within the window
+	Expiring []CertStatus
+	// Anomalies holds inconsistencies found by FindAnomalies
+	Anomalies []Anomaly
+}
+
+// NeedsAttention returns pending requests, certificates expiring within
+// expiryWindow and anomalies, from a single listing of the certificate
+// statuses. Certificate fetches are bounded by the client concurrency.
+func (c *Client) NeedsAttention(expiryWindow time.Duration) (*TriageReport, error) {
+	statuses, err := c.ListCertStatuses()
+	if err != nil {
+		return nil, err
+	}
+	anomalies, err := c.findAnomalies(statuses)
+	if err != nil {
+		return nil, err
+	}
+	report := &TriageReport{
+		Pending:   []CertStatus{},
+		Expiring:  expiringBefore(statuses, c.clock().Add(expiryWindow)),
+		Anomalies: anomalies,
+	}
+	for _, s := range statuses {
+		if s.State == "requested" {
+			report.Pending = append(report.Pending, s)
+		}
+	}
+	return report, nil
+}
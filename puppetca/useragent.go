@@ -0,0 +1,18 @@
+package puppetca
+
+// Version is the library version reported in the User-Agent header
+const Version = "0.1.0"
+
+// defaultUserAgent identifies the library in the CA's access logs
+const defaultUserAgent = "go-puppetca/" + Version
+
+// WithUserAgentSuffix appends a product token, such as "mytool/3.4", to the
+// default User-Agent, giving e.g. "go-puppetca/0.1.0 mytool/3.4". The
+// library identifier always stays first.
+func WithUserAgentSuffix(suffix string) Option {
+	return func(c *Client) {
+		if suffix != "" {
+			c.userAgent = defaultUserAgent + " " + suffix
+		}
+	}
+}